@@ -12,6 +12,7 @@ import (
 	"github.com/skamensky/shmutils/internal/docker"
 	"github.com/skamensky/shmutils/internal/promptify"
 	"github.com/skamensky/shmutils/internal/tz"
+	"github.com/skamensky/shmutils/pkg/command"
 	"github.com/spf13/cobra"
 )
 
@@ -51,6 +52,10 @@ func init() {
 		Use:   "docker",
 		Short: "Docker utilities",
 		Run: func(cmd *cobra.Command, args []string) {
+			if err := command.Require(docker.Dependencies...); err != nil {
+				fmt.Println(err)
+				return
+			}
 			docker.ShellIntoContainer()
 		},
 	}
@@ -208,6 +213,12 @@ func init() {
 				return
 			}
 	
+			includePatterns, err := cmd.Flags().GetStringSlice("only")
+			if err != nil {
+				fmt.Println("Error getting only flag:", err)
+				return
+			}
+	
 			ignorePatterns, err := cmd.Flags().GetStringSlice("ignore")
 			if err != nil {
 				fmt.Println("Error getting ignore flag:", err)
@@ -220,24 +231,117 @@ func init() {
 				return
 			}
 	
+			maxTokens, err := cmd.Flags().GetInt("max-tokens")
+			if err != nil {
+				fmt.Println("Error getting max-tokens flag:", err)
+				return
+			}
+	
+			budgetPolicyName, err := cmd.Flags().GetString("budget-policy")
+			if err != nil {
+				fmt.Println("Error getting budget-policy flag:", err)
+				return
+			}
+			budgetPolicy, err := promptify.ParseBudgetPolicy(budgetPolicyName)
+			if err != nil {
+				fmt.Println("Error getting budget-policy flag:", err)
+				return
+			}
+	
+			showTokens, err := cmd.Flags().GetBool("tokens")
+			if err != nil {
+				fmt.Println("Error getting tokens flag:", err)
+				return
+			}
+	
+			binaryModeName, err := cmd.Flags().GetString("binary")
+			if err != nil {
+				fmt.Println("Error getting binary flag:", err)
+				return
+			}
+			binaryMode, err := promptify.ParseBinaryMode(binaryModeName)
+			if err != nil {
+				fmt.Println("Error getting binary flag:", err)
+				return
+			}
+	
+			tracked, err := cmd.Flags().GetBool("tracked")
+			if err != nil {
+				fmt.Println("Error getting tracked flag:", err)
+				return
+			}
+	
+			staged, err := cmd.Flags().GetBool("staged")
+			if err != nil {
+				fmt.Println("Error getting staged flag:", err)
+				return
+			}
+	
+			diffRef, err := cmd.Flags().GetString("diff")
+			if err != nil {
+				fmt.Println("Error getting diff flag:", err)
+				return
+			}
+	
+			lastCommits, err := cmd.Flags().GetInt("last-commits")
+			if err != nil {
+				fmt.Println("Error getting last-commits flag:", err)
+				return
+			}
+	
+			gitMode := promptify.GitNone
+			gitModes := 0
+			if tracked {
+				gitMode = promptify.GitTracked
+				gitModes++
+			}
+			if staged {
+				gitMode = promptify.GitStaged
+				gitModes++
+			}
+			if diffRef != "" {
+				gitMode = promptify.GitDiff
+				gitModes++
+			}
+			if lastCommits > 0 {
+				gitMode = promptify.GitRecent
+				gitModes++
+			}
+			if gitModes > 1 {
+				fmt.Println("Only one of --tracked, --staged, --diff and --last-commits can be used")
+				return
+			}
+	
 			rootDir := args[0]
 	
 			opts := promptify.Options{
-				MaxDepth:       maxDepth,
-				RootDir:        rootDir,
-				FileFormat:     fileFormat,
-				PromptIntro:    promptIntro,
-				IgnorePatterns: ignorePatterns,
-				DryRun:         dryRun,
+				MaxDepth:        maxDepth,
+				RootDir:         rootDir,
+				FileFormat:      fileFormat,
+				PromptIntro:     promptIntro,
+				IncludePatterns: includePatterns,
+				IgnorePatterns:  ignorePatterns,
+				DryRun:          dryRun,
+				MaxTokens:       maxTokens,
+				CountTokens:     showTokens,
+				BudgetPolicy:    budgetPolicy,
+				BinaryMode:      binaryMode,
+				GitMode:         gitMode,
+				GitRef:          diffRef,
+				GitCommits:      lastCommits,
 			}
 	
-			result, err := promptify.Promptify(opts)
+			result, report, err := promptify.Generate(opts)
 			if err != nil {
 				fmt.Printf("Error generating prompt: %s\n", err)
 				return
 			}
 	
 			fmt.Print(result)
+			if showTokens && report != nil {
+				// on stderr, so that the prompt can still be piped
+				fmt.Fprint(os.Stderr, report)
+			}
 		},
 	}
 	
@@ -254,12 +358,26 @@ func init() {
 			"Output files using your normal markdown format.",
 		"Introduction template",
 	)
-	// Support multiple --ignore flags, e.g.:
-	//    --ignore="*.md" --ignore="node_modules"
-	promptifyCmd.Flags().StringSlice("ignore", []string{}, "List of file/directory name patterns to ignore")
+	// Support multiple --only and --ignore flags, e.g.:
+	//    --only='**/*.go' --ignore="*.md" --ignore="node_modules" --ignore='**/vendor/**'
+	// Patterns with a slash match paths relative to the directory, others match names.
+	promptifyCmd.Flags().StringSlice("only", []string{}, "List of file patterns to include, all files if empty")
+	promptifyCmd.Flags().StringSlice("ignore", []string{}, "List of file/directory patterns to ignore, applied after --only")
 
 	promptifyCmd.Flags().Bool("dry-run", false, "If set, only prints the list of included file names (no content)")
 
+	promptifyCmd.Flags().Int("max-tokens", 0, "Maximum size of the prompt in tokens (0 means unlimited)")
+	promptifyCmd.Flags().String("budget-policy", "drop", `What to do with files that don't fit in --max-tokens: "drop" or "truncate"`)
+	promptifyCmd.Flags().Bool("tokens", false, "If set, prints the token count of each file to stderr")
+	promptifyCmd.Flags().String("binary", "skip", `What to do with binary files: "skip", "summarize" or "base64"`)
+
+	// Select files from git instead of walking the directory, e.g. to promptify a pull request:
+	//    --diff main
+	promptifyCmd.Flags().Bool("tracked", false, "If set, only includes files tracked by git")
+	promptifyCmd.Flags().Bool("staged", false, "If set, only includes files with staged changes")
+	promptifyCmd.Flags().String("diff", "", "Only includes files changed since the branch point with this git ref, including uncommitted changes")
+	promptifyCmd.Flags().Int("last-commits", 0, "Only includes files changed by the last N commits")
+
 	rootCmd.AddCommand(promptifyCmd)
 	rootCmd.AddCommand(dockerCmd)
 	rootCmd.AddCommand(calcCmd)
@@ -19,7 +19,7 @@ func Calculate(expression string, interactiveMode bool) (interface{}, error) {
 			}
 			res, err := p.Run()
 			if err != nil {
-				fmt.Println("Something weird happened: %v", err)
+				fmt.Printf("Something weird happened: %v\n", err)
 			}
 
 			if strings.Contains(res, "=") {
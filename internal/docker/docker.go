@@ -8,26 +8,36 @@ import (
 	"github.com/skamensky/shmutils/pkg/command"
 )
 
+// Dependencies are the executables the docker utilities need.
+var Dependencies = []string{"docker"}
+
+func init() {
+	command.RegisterDependency(command.Dependency{
+		Name:        "docker",
+		VersionArgs: []string{"--version"},
+		InstallHint: "install Docker from https://docs.docker.com/get-docker/",
+	})
+}
+
 func GetDockerContainers() (string, error) {
-	res := command.New("docker", "container", "ls").WithTreatStderrAsErr(true).Run()
+	res := command.New("docker", "container", "ls").Run()
 	return res.Stdout.String(), res.Err
 }
 
 func AttachToDockerContainer(containerId string) error {
-	getCommand := func(shell string) string {
-		return fmt.Sprintf("docker exec -it %s %s", containerId, shell)
+	getCommand := func(shell string) *command.Command {
+		return command.New("docker", "exec", "-it", containerId, shell)
 	}
-	cmd := command.New("bash", "-c", getCommand("bash")).WithTreatStderrAsErr(true).WithAttachToTerminal(true)
+	cmd := getCommand("bash").WithAttachToTerminal(true).WithPTY(true)
 	res := cmd.Run()
 	if res.Err != nil {
 		fmt.Println("Couldn't run bash, trying sh")
-		cmd = command.New("bash", "-c", getCommand("sh")).WithTreatStderrAsErr(true).WithAttachToTerminal(true)
+		cmd = getCommand("sh").WithAttachToTerminal(true).WithPTY(true)
 		res = cmd.Run()
 		if res.Err != nil {
 			return res.Err
 		}
 	}
-	fmt.Println(res.Stderr.String())
 	return nil
 }
 
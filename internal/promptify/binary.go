@@ -0,0 +1,142 @@
+package promptify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// BinaryMode decides how binary files (images, executables, archives...) are put in the prompt.
+type BinaryMode int
+
+const (
+	// SkipBinary leaves binary files out of the prompt.
+	SkipBinary BinaryMode = iota
+	// SummarizeBinary replaces the content of binary files with a line giving their size and type.
+	SummarizeBinary
+	// EmbedBinary puts the content of binary files in the prompt, base64-encoded, after the summary line.
+	EmbedBinary
+)
+
+// ParseBinaryMode parses "skip", "summarize" or "base64".
+func ParseBinaryMode(s string) (BinaryMode, error) {
+	switch s {
+	case "skip":
+		return SkipBinary, nil
+	case "summarize":
+		return SummarizeBinary, nil
+	case "base64":
+		return EmbedBinary, nil
+	}
+	return 0, fmt.Errorf("unknown binary mode %q, expected \"skip\", \"summarize\" or \"base64\"", s)
+}
+
+// sniffLen is how much of a file is looked at to decide whether it is binary, the same as git does.
+const sniffLen = 8000
+
+// maxInvalidUTF8Ratio is the share of bytes that aren't valid UTF-8 above which a file is considered binary.
+const maxInvalidUTF8Ratio = 0.1
+
+// sniffedFile is what sniffing a file found out about it.
+type sniffedFile struct {
+	path     string
+	size     int64
+	binary   bool
+	mimeType string
+}
+
+// sniffFile reads the beginning of a file to tell whether it is binary.
+func sniffFile(path string) (sniffedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return sniffedFile{}, fmt.Errorf("failed to read file %q: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return sniffedFile{}, fmt.Errorf("failed to read file %q: %w", path, err)
+	}
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return sniffedFile{}, fmt.Errorf("failed to read file %q: %w", path, err)
+	}
+	binary, mimeType := detectBinary(head[:n])
+	return sniffedFile{path: path, size: info.Size(), binary: binary, mimeType: mimeType}, nil
+}
+
+// detectBinary tells whether content is binary, from a NUL byte or a high ratio of invalid UTF-8, and returns its MIME
+// type. The MIME type only labels the content: it is sniffed from magic prefixes, which ordinary text can start with
+// too (e.g. "BM" for BMP images or "ID3" for MP3s).
+func detectBinary(content []byte) (bool, string) {
+	mimeType := http.DetectContentType(content)
+	if bytes.IndexByte(content, 0) >= 0 {
+		return true, mimeType
+	}
+
+	invalid := 0
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRune(content[i:])
+		// a character cut off at the end of the sniffed content isn't invalid
+		if r == utf8.RuneError && size == 1 && !(len(content) == sniffLen && len(content)-i < utf8.UTFMax) {
+			invalid++
+		}
+		i += size
+	}
+	if len(content) > 0 && float64(invalid)/float64(len(content)) > maxInvalidUTF8Ratio {
+		return true, mimeType
+	}
+
+	if !isTextMIMEType(mimeType) {
+		mimeType = "text/plain; charset=utf-8"
+	}
+	return false, mimeType
+}
+
+func isTextMIMEType(mimeType string) bool {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		return true
+	case mimeType == "application/json", mimeType == "application/xml", mimeType == "application/javascript",
+		mimeType == "application/postscript", mimeType == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// binaryContent is what is put in the prompt in place of the content of a binary file.
+func binaryContent(mode BinaryMode, file sniffedFile, content []byte) string {
+	summary := fmt.Sprintf("[binary file: %s, %d bytes]", file.mimeType, file.size)
+	if mode != EmbedBinary {
+		return summary
+	}
+	encoded := base64.StdEncoding.EncodeToString(content)
+	var sb strings.Builder
+	sb.WriteString(summary + "\nbase64:\n")
+	// wrapped like MIME does, so that the prompt stays readable
+	for len(encoded) > 76 {
+		sb.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	sb.WriteString(encoded)
+	return sb.String()
+}
+
+// skippedList lists the binary files left out of a dry run, after the included ones.
+func skippedList(skipped []sniffedFile) string {
+	if len(skipped) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\nSkipped binary files:\n")
+	for _, file := range skipped {
+		fmt.Fprintf(&sb, "%s (%s, %d bytes)\n", file.path, file.mimeType, file.size)
+	}
+	return sb.String()
+}
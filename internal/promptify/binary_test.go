@@ -0,0 +1,76 @@
+package promptify
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDetectBinary(t *testing.T) {
+	// a multi-byte character cut off by the end of the sniffed content
+	cutOff := []byte(strings.Repeat("a", sniffLen-1) + "é")[:sniffLen]
+	tests := []struct {
+		name       string
+		content    []byte
+		wantBinary bool
+		wantMIME   string
+	}{
+		{"empty", nil, false, "text/plain; charset=utf-8"},
+		{"source code", []byte("package main\n\nfunc main() {}\n"), false, "text/plain; charset=utf-8"},
+		{"utf-8 text", []byte("naïve café, 日本語\n"), false, "text/plain; charset=utf-8"},
+		{"html", []byte("<!DOCTYPE html><html></html>"), false, "text/html; charset=utf-8"},
+		{"text starting like a bmp", []byte("BMW fleet inventory\nX5: 3\n"), false, "text/plain; charset=utf-8"},
+		{"text starting like an mp3", []byte("ID3 tags are read by the player\n"), false, "text/plain; charset=utf-8"},
+		{"text starting like a gif", []byte("GIF89a is the animated variant\n"), false, "text/plain; charset=utf-8"},
+		{"character cut off at the end", cutOff, false, "text/plain; charset=utf-8"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), true, "image/png"},
+		{"nul byte in text", []byte("almost text\x00"), true, "application/octet-stream"},
+		{"latin-1 text", bytes.Repeat([]byte("caf\xe9 "), 10), true, "text/plain; charset=utf-8"},
+		{"a few invalid bytes", []byte(strings.Repeat("valid text ", 10) + "\xff"), false, "text/plain; charset=utf-8"},
+	}
+	for _, test := range tests {
+		binary, mimeType := detectBinary(test.content)
+		if binary != test.wantBinary || mimeType != test.wantMIME {
+			t.Errorf("%s: got binary %v and %q, want %v and %q", test.name, binary, mimeType, test.wantBinary, test.wantMIME)
+		}
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"notes.txt": []byte("BMW fleet inventory\n"),
+		"image.gif": []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"),
+		// only the start of a file is looked at
+		"big.txt": append(bytes.Repeat([]byte("text\n"), sniffLen), 0),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name       string
+		wantBinary bool
+		wantMIME   string
+	}{
+		{"notes.txt", false, "text/plain; charset=utf-8"},
+		{"image.gif", true, "image/gif"},
+		{"big.txt", false, "text/plain; charset=utf-8"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name)
+		file, err := sniffFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.path != path || file.size != int64(len(files[test.name])) || file.binary != test.wantBinary || file.mimeType != test.wantMIME {
+			t.Errorf("%s: got %+v, want binary %v and %q", test.name, file, test.wantBinary, test.wantMIME)
+		}
+	}
+	if _, err := sniffFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("sniffing a missing file succeeded")
+	}
+}
@@ -0,0 +1,195 @@
+package promptify
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// BudgetPolicy decides what happens to files that don't fit in Options.MaxTokens.
+type BudgetPolicy int
+
+const (
+	// DropFiles leaves out whole files that don't fit, going from the highest priority file to the lowest.
+	DropFiles BudgetPolicy = iota
+	// TruncateFiles shortens the first file that doesn't fit to the tokens that are left, and leaves out the rest.
+	TruncateFiles
+)
+
+// ParseBudgetPolicy parses "drop" or "truncate".
+func ParseBudgetPolicy(s string) (BudgetPolicy, error) {
+	switch s {
+	case "drop":
+		return DropFiles, nil
+	case "truncate":
+		return TruncateFiles, nil
+	}
+	return 0, fmt.Errorf("unknown budget policy %q, expected \"drop\" or \"truncate\"", s)
+}
+
+// DefaultPriority prefers files closer to the root, which tend to be the most general ones (READMEs, manifests,
+// entrypoints).
+func DefaultPriority(path string) int {
+	return -strings.Count(filepath.ToSlash(path), "/")
+}
+
+// truncatedMarker is appended to the content of truncated files.
+const truncatedMarker = "\n[... truncated to fit the token budget ...]"
+
+// FileStatus tells what happened to a file when generating the prompt.
+type FileStatus string
+
+const (
+	FileIncluded  FileStatus = "included"
+	FileTruncated FileStatus = "truncated"
+	FileDropped   FileStatus = "dropped"
+	// FileSkipped is the status of binary files left out with SkipBinary.
+	FileSkipped FileStatus = "skipped"
+)
+
+// FileReport holds the token count of a single file, as rendered with FileFormat.
+type FileReport struct {
+	Path   string
+	Tokens int
+	// IncludedTokens is the number of tokens that made it into the prompt, less than Tokens if the file was truncated.
+	IncludedTokens int
+	Status         FileStatus
+	// MIMEType is the sniffed type of binary files, and empty for text files.
+	MIMEType string
+}
+
+// Report describes the files of a generated prompt and their sizes.
+type Report struct {
+	Files []FileReport
+	// IntroTokens is the size of the rendered PromptIntro.
+	IntroTokens int
+	// TotalTokens is the size of the whole prompt.
+	TotalTokens int
+	// MaxTokens is the budget the prompt was generated with, or 0 if there was none.
+	MaxTokens int
+}
+
+// String renders the report as a table with a line per file.
+func (r *Report) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "TOKENS\tINCLUDED\tSTATUS\t FILE")
+	for _, file := range r.Files {
+		path := file.Path
+		if file.MIMEType != "" {
+			path += " (" + file.MIMEType + ")"
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\t %s\n", file.Tokens, file.IncludedTokens, file.Status, path)
+	}
+	w.Flush()
+	if r.MaxTokens > 0 {
+		fmt.Fprintf(&sb, "total: %d tokens (intro: %d, budget: %d)\n", r.TotalTokens, r.IntroTokens, r.MaxTokens)
+	} else {
+		fmt.Fprintf(&sb, "total: %d tokens (intro: %d)\n", r.TotalTokens, r.IntroTokens)
+	}
+	return sb.String()
+}
+
+// renderedFile is a file's content rendered with FileFormat.
+type renderedFile struct {
+	path     string
+	content  string
+	text     string
+	tokens   int
+	mimeType string
+	// skipped is set for binary files left out of the prompt, which aren't rendered
+	skipped bool
+}
+
+// applyBudget decides which files fit in budget, the tokens left after the intro, and truncates one of them if the
+// policy says so. The text of truncated files is replaced, and the returned reports are in the original file order.
+func applyBudget(files []*renderedFile, budget int, opts Options, tokenizer Tokenizer, fileTmpl *template.Template) ([]FileReport, error) {
+	reports := make([]FileReport, len(files))
+	for i, file := range files {
+		reports[i] = FileReport{Path: file.path, Tokens: file.tokens, IncludedTokens: file.tokens, Status: FileIncluded, MIMEType: file.mimeType}
+		if file.skipped {
+			reports[i].Status = FileSkipped
+		}
+	}
+	if opts.MaxTokens <= 0 {
+		return reports, nil
+	}
+
+	priority := opts.Priority
+	if priority == nil {
+		priority = DefaultPriority
+	}
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return priority(files[order[a]].path) > priority(files[order[b]].path)
+	})
+
+	truncated := false
+	for _, i := range order {
+		file := files[i]
+		if file.skipped {
+			continue
+		}
+		if file.tokens <= budget {
+			budget -= file.tokens
+			continue
+		}
+		reports[i].Status = FileDropped
+		reports[i].IncludedTokens = 0
+		if opts.BudgetPolicy != TruncateFiles || truncated {
+			continue
+		}
+		// only the first file that doesn't fit is truncated, there's little budget left after it
+		truncated = true
+		text, tokens, err := truncateToBudget(file, budget, tokenizer, fileTmpl)
+		if err != nil {
+			return nil, err
+		}
+		if tokens > 0 {
+			budget -= tokens
+			file.text = text
+			reports[i].Status = FileTruncated
+			reports[i].IncludedTokens = tokens
+		}
+	}
+	return reports, nil
+}
+
+// truncateToBudget renders the longest prefix of the file's lines, followed by truncatedMarker, that fits in budget.
+// It returns 0 tokens if not even the file's header fits.
+func truncateToBudget(file *renderedFile, budget int, tokenizer Tokenizer, fileTmpl *template.Template) (string, int, error) {
+	lines := strings.SplitAfter(file.content, "\n")
+	render := func(n int) (string, int, error) {
+		text, err := renderFile(fileTmpl, file.path, strings.Join(lines[:n], "")+truncatedMarker)
+		if err != nil {
+			return "", 0, err
+		}
+		return text, tokenizer.CountTokens(text), nil
+	}
+
+	// binary search for the largest number of lines that fits
+	lo, hi := 0, len(lines)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		_, tokens, err := render(mid)
+		if err != nil {
+			return "", 0, err
+		}
+		if tokens <= budget {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	text, tokens, err := render(lo)
+	if err != nil || tokens > budget {
+		return "", 0, err
+	}
+	return text, tokens, nil
+}
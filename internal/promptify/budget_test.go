@@ -0,0 +1,198 @@
+package promptify
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// wordTokenizer counts words, which makes budgets easy to work out in tests.
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+var testFileTmpl = template.Must(template.New("fileFormat").Parse("{{.FileName}}\n{{.Content}}"))
+
+func renderTestFiles(t *testing.T, contents map[string]string, order ...string) []*renderedFile {
+	t.Helper()
+	var files []*renderedFile
+	for _, path := range order {
+		text, err := renderFile(testFileTmpl, path, contents[path])
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &renderedFile{path: path, content: contents[path], text: text, tokens: wordTokenizer{}.CountTokens(text)})
+	}
+	return files
+}
+
+func statuses(reports []FileReport) []string {
+	var result []string
+	for _, report := range reports {
+		result = append(result, report.Path+":"+string(report.Status))
+	}
+	return result
+}
+
+func TestApplyBudgetDropsFiles(t *testing.T) {
+	contents := map[string]string{
+		"a.go":         "one two three four five six seven eight nine ten",
+		"dir/b.go":     "one two three",
+		"dir/sub/c.go": "one two three four five",
+	}
+	tests := []struct {
+		name     string
+		opts     Options
+		budget   int
+		want     []string
+		included []int
+	}{
+		{
+			"no budget", Options{}, 0,
+			[]string{"a.go:included", "dir/b.go:included", "dir/sub/c.go:included"}, []int{11, 4, 6},
+		},
+		{
+			"default priority", Options{MaxTokens: 100}, 16,
+			[]string{"a.go:included", "dir/b.go:included", "dir/sub/c.go:dropped"}, []int{11, 4, 0},
+		},
+		{
+			"a file that fits after one that doesn't", Options{MaxTokens: 100}, 10,
+			[]string{"a.go:dropped", "dir/b.go:included", "dir/sub/c.go:included"}, []int{0, 4, 6},
+		},
+		{
+			"custom priority", Options{MaxTokens: 100, Priority: func(path string) int { return len(path) }}, 10,
+			[]string{"a.go:dropped", "dir/b.go:included", "dir/sub/c.go:included"}, []int{0, 4, 6},
+		},
+	}
+	for _, test := range tests {
+		files := renderTestFiles(t, contents, "a.go", "dir/b.go", "dir/sub/c.go")
+		reports, err := applyBudget(files, test.budget, test.opts, wordTokenizer{}, testFileTmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := statuses(reports); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		for i, report := range reports {
+			if report.IncludedTokens != test.included[i] {
+				t.Errorf("%s: %s has %d included tokens, want %d", test.name, report.Path, report.IncludedTokens, test.included[i])
+			}
+		}
+	}
+}
+
+func TestApplyBudgetTruncatesFirstFileThatDoesntFit(t *testing.T) {
+	contents := map[string]string{
+		"a.go": "w1\nw2\nw3\nw4\nw5\nw6\nw7\nw8\nw9\nw10\n",
+		"b.go": "x y z",
+	}
+	files := renderTestFiles(t, contents, "a.go", "b.go")
+	// the header, a line and the 8 words of the truncation marker
+	budget := 1 + 1 + 8
+	reports, err := applyBudget(files, budget, Options{MaxTokens: 100, BudgetPolicy: TruncateFiles}, wordTokenizer{}, testFileTmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := statuses(reports), []string{"a.go:truncated", "b.go:dropped"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if reports[0].Tokens != 11 || reports[0].IncludedTokens != budget {
+		t.Errorf("a.go has %d of %d tokens included, want %d of 11", reports[0].IncludedTokens, reports[0].Tokens, budget)
+	}
+	if text := files[0].text; !strings.Contains(text, "w1\n"+truncatedMarker) || strings.Contains(text, "w2") {
+		t.Errorf("a.go was truncated to %q", text)
+	}
+}
+
+func TestTruncateToBudget(t *testing.T) {
+	file := renderTestFiles(t, map[string]string{"a.go": "one\ntwo\nthree\n"}, "a.go")[0]
+	for budget, wantLines := range map[int]int{8: -1, 9: 0, 10: 1, 12: 3, 100: 3} {
+		text, tokens, err := truncateToBudget(file, budget, wordTokenizer{}, testFileTmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantLines < 0 {
+			if tokens != 0 || text != "" {
+				t.Errorf("budget %d: got %q, want nothing since not even the header fits", budget, text)
+			}
+			continue
+		}
+		if tokens > budget || tokens != 1+wantLines+8 {
+			t.Errorf("budget %d: got %d tokens (%q), want %d lines", budget, tokens, text, wantLines)
+		}
+	}
+}
+
+func TestApplyBudgetIgnoresSkippedFiles(t *testing.T) {
+	files := renderTestFiles(t, map[string]string{"a.go": "one two", "image.png": ""}, "image.png", "a.go")
+	files[0].skipped, files[0].tokens = true, 0
+	reports, err := applyBudget(files, 3, Options{MaxTokens: 100}, wordTokenizer{}, testFileTmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := statuses(reports), []string{"image.png:skipped", "a.go:included"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseBudgetPolicy(t *testing.T) {
+	for s, want := range map[string]BudgetPolicy{"drop": DropFiles, "truncate": TruncateFiles} {
+		if got, err := ParseBudgetPolicy(s); err != nil || got != want {
+			t.Errorf("ParseBudgetPolicy(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	if _, err := ParseBudgetPolicy("shrink"); err == nil {
+		t.Error("ParseBudgetPolicy accepted an unknown policy")
+	}
+}
+
+func TestGenerateDryRunCountsTokens(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.txt":     "one two three\n",
+		"sub/b.txt": "four five\n",
+	})
+	opts := Options{
+		RootDir:     root,
+		PromptIntro: "intro",
+		FileFormat:  "{{.Content}}",
+		DryRun:      true,
+		Tokenizer:   wordTokenizer{},
+	}
+
+	listing, report, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != nil {
+		t.Errorf("got a report without CountTokens: %+v", report)
+	}
+
+	opts.CountTokens = true
+	counted, report, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counted != listing {
+		t.Errorf("dry run listing changed with CountTokens:\n%s\nwant:\n%s", counted, listing)
+	}
+	if report == nil {
+		t.Fatal("no report with CountTokens")
+	}
+	var tokens []int
+	for _, file := range report.Files {
+		if file.Status != FileIncluded {
+			t.Errorf("%s: status %s, want %s", file.Path, file.Status, FileIncluded)
+		}
+		tokens = append(tokens, file.Tokens)
+	}
+	if want := []int{3, 2}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("file tokens = %v, want %v", tokens, want)
+	}
+	if report.TotalTokens != report.IntroTokens+5 {
+		t.Errorf("total = %d, want intro (%d) + 5", report.TotalTokens, report.IntroTokens)
+	}
+}
@@ -0,0 +1,252 @@
+package promptify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// GitMode selects files from git instead of walking the file system.
+type GitMode int
+
+const (
+	// GitNone walks the file system, respecting the ignore rules. It is the default.
+	GitNone GitMode = iota
+	// GitTracked selects the files tracked by git.
+	GitTracked
+	// GitDiff selects the files changed since Options.GitRef: the changes of the commits since the branch point
+	// (merge base) with GitRef, plus the staged and unstaged changes, like a pull request against GitRef would show.
+	GitDiff
+	// GitStaged selects the files with staged changes.
+	GitStaged
+	// GitRecent selects the files changed by the last Options.GitCommits commits.
+	GitRecent
+)
+
+// gitFiles returns the files of the repository containing root that the git mode selects, and still exist, as
+// paths under root (like collectFiles), in lexical order.
+func gitFiles(root string, opts Options) ([]string, error) {
+	repo, err := git.PlainOpenWithOptions(root, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository at %q: %w", root, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git worktree: %w", err)
+	}
+
+	var names map[string]bool
+	switch opts.GitMode {
+	case GitTracked:
+		names, err = trackedFiles(repo)
+	case GitDiff:
+		names, err = diffFiles(repo, wt, opts.GitRef)
+	case GitStaged:
+		names, err = changedFiles(wt, true)
+	case GitRecent:
+		names, err = recentFiles(repo, opts.GitCommits)
+	default:
+		return nil, fmt.Errorf("unknown git mode %d", opts.GitMode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// git paths are relative to the repository root, while the result is relative to root
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory %q: %w", root, err)
+	}
+	repoRoot, err := filepath.EvalSymlinks(wt.Filesystem.Root())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve repository root: %w", err)
+	}
+	if resolvedRoot, err := filepath.EvalSymlinks(absRoot); err == nil {
+		absRoot = resolvedRoot
+	}
+	prefix, err := filepath.Rel(repoRoot, absRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory %q: %w", root, err)
+	}
+
+	var result []string
+	for name := range names {
+		rel, err := filepath.Rel(prefix, filepath.FromSlash(name))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		depth := len(strings.Split(rel, string(filepath.Separator)))
+		if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+			continue
+		}
+		if !selectedByPatterns(rel, opts.IncludePatterns, opts.IgnorePatterns) {
+			continue
+		}
+		path := filepath.Join(root, rel)
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			// deleted since, or a submodule
+			continue
+		}
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func trackedFiles(repo *git.Repository) (map[string]bool, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git index: %w", err)
+	}
+	names := map[string]bool{}
+	for _, entry := range idx.Entries {
+		names[entry.Name] = true
+	}
+	return names, nil
+}
+
+// changedFiles returns the files with staged changes, and unless stagedOnly also the ones with unstaged changes.
+// Untracked files are left out, like git diff does.
+func changedFiles(wt *git.Worktree, stagedOnly bool) (map[string]bool, error) {
+	status, err := wt.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git status: %w", err)
+	}
+	names := map[string]bool{}
+	for name, fileStatus := range status {
+		if fileStatus.Staging == git.Untracked {
+			continue
+		}
+		if fileStatus.Staging != git.Unmodified || !stagedOnly && fileStatus.Worktree != git.Unmodified {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+func diffFiles(repo *git.Repository, wt *git.Worktree, ref string) (map[string]bool, error) {
+	if ref == "" {
+		return nil, errors.New("a ref to diff against must be specified")
+	}
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", ref, err)
+	}
+	refCommit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", hash, err)
+	}
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+	bases, err := head.MergeBase(refCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the merge base of HEAD and %q: %w", ref, err)
+	}
+	if len(bases) == 0 {
+		return nil, fmt.Errorf("HEAD and %q have no common history", ref)
+	}
+
+	names, err := commitChanges(bases[0], head)
+	if err != nil {
+		return nil, err
+	}
+	uncommitted, err := changedFiles(wt, false)
+	if err != nil {
+		return nil, err
+	}
+	for name := range uncommitted {
+		names[name] = true
+	}
+	return names, nil
+}
+
+func recentFiles(repo *git.Repository, commits int) (map[string]bool, error) {
+	if commits <= 0 {
+		return nil, errors.New("the number of commits must be positive")
+	}
+	head, err := headCommit(repo)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := repo.Log(&git.LogOptions{From: head.Hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git log: %w", err)
+	}
+	defer iter.Close()
+
+	names := map[string]bool{}
+	for i := 0; i < commits; i++ {
+		commit, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read git log: %w", err)
+		}
+		// changes are relative to the first parent, or to nothing for the root commit
+		var parent *object.Commit
+		if commit.NumParents() > 0 {
+			if parent, err = commit.Parent(0); err != nil {
+				return nil, fmt.Errorf("failed to read parent of commit %s: %w", commit.Hash, err)
+			}
+		}
+		changed, err := commitChanges(parent, commit)
+		if err != nil {
+			return nil, err
+		}
+		for name := range changed {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+func headCommit(repo *git.Repository) (*object.Commit, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", head.Hash(), err)
+	}
+	return commit, nil
+}
+
+// commitChanges returns the files added or modified between two commits; from may be nil for an empty tree.
+func commitChanges(from *object.Commit, to *object.Commit) (map[string]bool, error) {
+	var fromTree *object.Tree
+	if from != nil {
+		tree, err := from.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tree of commit %s: %w", from.Hash, err)
+		}
+		fromTree = tree
+	}
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of commit %s: %w", to.Hash, err)
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff commit %s: %w", to.Hash, err)
+	}
+	names := map[string]bool{}
+	for _, change := range changes {
+		// deleted files have no name on the "to" side
+		if change.To.Name != "" {
+			names[change.To.Name] = true
+		}
+	}
+	return names, nil
+}
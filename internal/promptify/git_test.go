@@ -0,0 +1,216 @@
+package promptify
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitFixture builds a repository with a "feature" branch that has diverged from master:
+//
+//	master:  root commit (a.txt, old.txt, gone.txt, unstaged.txt, sub/c.txt) -> sub/c.txt modified
+//	feature: root commit -> a.txt modified, old.txt renamed to new.txt, gone.txt deleted
+//
+// feature is checked out, with staged.txt added to the index, unstaged.txt modified but not staged, and an
+// untracked untracked.txt.
+func gitFixture(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := func(msg string) {
+		t.Helper()
+		if _, err := wt.Add("."); err != nil {
+			t.Fatal(err)
+		}
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+		if _, err := wt.Commit(msg, &git.CommitOptions{Author: sig}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkout := func(branch string, create bool) {
+		t.Helper()
+		if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Create: create}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFiles(t, root, map[string]string{
+		"a.txt":        "a\n",
+		"old.txt":      "renamed on feature\n",
+		"gone.txt":     "deleted on feature\n",
+		"unstaged.txt": "committed\n",
+		"sub/c.txt":    "c\n",
+	})
+	commit("root")
+
+	checkout("feature", true)
+	writeFiles(t, root, map[string]string{"a.txt": "a changed on feature\n"})
+	if _, err := wt.Move("old.txt", "new.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Remove("gone.txt"); err != nil {
+		t.Fatal(err)
+	}
+	commit("feature")
+
+	// a change on master after the branch point, which a pull request from feature wouldn't show
+	checkout("master", false)
+	writeFiles(t, root, map[string]string{"sub/c.txt": "c changed on master\n"})
+	commit("master")
+
+	checkout("feature", false)
+	writeFiles(t, root, map[string]string{
+		"staged.txt":    "staged\n",
+		"unstaged.txt":  "changed but not staged\n",
+		"untracked.txt": "untracked\n",
+	})
+	if _, err := wt.Add("staged.txt"); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestGitFiles(t *testing.T) {
+	root := gitFixture(t)
+	tests := []struct {
+		name string
+		dir  string
+		opts Options
+		want []string
+	}{
+		{
+			name: "tracked files, without deleted, renamed away or untracked ones",
+			opts: Options{GitMode: GitTracked},
+			want: []string{"a.txt", "new.txt", "staged.txt", "sub/c.txt", "unstaged.txt"},
+		},
+		{
+			name: "tracked files under a subdirectory",
+			dir:  "sub",
+			opts: Options{GitMode: GitTracked},
+			want: []string{"c.txt"},
+		},
+		{
+			name: "staged files only",
+			opts: Options{GitMode: GitStaged},
+			want: []string{"staged.txt"},
+		},
+		{
+			name: "diff since the merge base, with uncommitted changes",
+			opts: Options{GitMode: GitDiff, GitRef: "master"},
+			want: []string{"a.txt", "new.txt", "staged.txt", "unstaged.txt"},
+		},
+		{
+			name: "diff against the branch point itself",
+			opts: Options{GitMode: GitDiff, GitRef: "feature~1"},
+			want: []string{"a.txt", "new.txt", "staged.txt", "unstaged.txt"},
+		},
+		{
+			name: "last commit",
+			opts: Options{GitMode: GitRecent, GitCommits: 1},
+			want: []string{"a.txt", "new.txt"},
+		},
+		{
+			name: "last commits down to the root commit",
+			opts: Options{GitMode: GitRecent, GitCommits: 2},
+			want: []string{"a.txt", "new.txt", "sub/c.txt", "unstaged.txt"},
+		},
+		{
+			name: "more commits than the history has",
+			opts: Options{GitMode: GitRecent, GitCommits: 10},
+			want: []string{"a.txt", "new.txt", "sub/c.txt", "unstaged.txt"},
+		},
+		{
+			name: "patterns still apply",
+			opts: Options{GitMode: GitTracked, IgnorePatterns: []string{"sub"}, IncludePatterns: []string{"*.txt"}},
+			want: []string{"a.txt", "new.txt", "staged.txt", "unstaged.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(root, filepath.FromSlash(tt.dir))
+			files, err := gitFiles(dir, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, file := range files {
+				rel, err := filepath.Rel(dir, file)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitFilesErrors(t *testing.T) {
+	root := gitFixture(t)
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"diff without a ref", Options{GitMode: GitDiff}},
+		{"diff against an unknown ref", Options{GitMode: GitDiff, GitRef: "nope"}},
+		{"no commits", Options{GitMode: GitRecent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := gitFiles(root, tt.opts); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := gitFiles(t.TempDir(), Options{GitMode: GitTracked}); err == nil {
+		t.Error("expected an error outside of a repository")
+	}
+}
+
+func TestCommitChangesLeavesOutDeletedFiles(t *testing.T) {
+	root := gitFixture(t)
+	repo, err := git.PlainOpen(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := headCommit(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := head.Parent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := commitChanges(parent, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gone.txt was deleted and old.txt renamed to new.txt
+	if want := map[string]bool{"a.txt": true, "new.txt": true}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	names, err = commitChanges(nil, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"a.txt": true, "old.txt": true, "gone.txt": true, "unstaged.txt": true, "sub/c.txt": true}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("root commit: got %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,247 @@
+package promptify
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// ignoreMatcher applies git's ignore rules: the patterns of core.excludesFile, then .git/info/exclude, then the
+// .gitignore of every directory from the repository root down, each one overriding the ones before it (including
+// with negated patterns). Like git, files in the index are never ignored, whatever the patterns say. Outside of a git
+// repository, only the .gitignore files under the root directory are used.
+type ignoreMatcher struct {
+	repoRoot string
+	// prefix is the path of the root directory relative to repoRoot
+	prefix []string
+	// global holds the patterns of core.excludesFile and .git/info/exclude, in ascending order of priority
+	global []gitignore.Pattern
+	// dirs caches the patterns of the .gitignore of each directory, by its slash-separated path relative to repoRoot
+	dirs map[string][]gitignore.Pattern
+	// tracked holds the files in the index, and trackedDirs the directories containing them, by their slash-separated
+	// path relative to repoRoot
+	tracked     map[string]bool
+	trackedDirs map[string]bool
+}
+
+func newIgnoreMatcher(root string) (*ignoreMatcher, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve root directory %q: %w", root, err)
+	}
+	m := &ignoreMatcher{repoRoot: absRoot, dirs: map[string][]gitignore.Pattern{}}
+
+	repoRoot, ok := findRepoRoot(absRoot)
+	if !ok {
+		return m, nil
+	}
+	m.repoRoot = repoRoot
+	if rel, _ := filepath.Rel(repoRoot, absRoot); rel != "." {
+		m.prefix = strings.Split(filepath.ToSlash(rel), "/")
+	}
+
+	commonDir, err := gitCommonDir(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if excludesFile := coreExcludesFile(commonDir); excludesFile != "" {
+		if m.global, err = readIgnoreFile(excludesFile, nil); err != nil {
+			return nil, err
+		}
+	}
+	infoExclude, err := readIgnoreFile(filepath.Join(commonDir, "info", "exclude"), nil)
+	if err != nil {
+		return nil, err
+	}
+	m.global = append(m.global, infoExclude...)
+
+	repo, err := git.PlainOpenWithOptions(repoRoot, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository %q: %w", repoRoot, err)
+	}
+	if m.tracked, err = trackedFiles(repo); err != nil {
+		return nil, err
+	}
+	m.trackedDirs = map[string]bool{}
+	for name := range m.tracked {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			m.trackedDirs[dir] = true
+		}
+	}
+	return m, nil
+}
+
+// findRepoRoot returns the closest directory containing dir that has a .git directory or file (for worktrees).
+func findRepoRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+/*
+gitCommonDir returns the directory holding the config and info/exclude of the repository at repoRoot. That is its
+.git directory, unless .git is a file, as in worktrees and submodules: it then points to the actual git directory
+with a "gitdir: <path>" line, and in the case of a worktree, that one points to the main repository's with a
+commondir file.
+*/
+func gitCommonDir(repoRoot string) (string, error) {
+	gitDir := filepath.Join(repoRoot, ".git")
+	info, err := os.Stat(gitDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read git directory: %w", err)
+	}
+	if !info.IsDir() {
+		content, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to read git directory: %w", err)
+		}
+		line := strings.TrimSpace(string(content))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return "", fmt.Errorf("%q is neither a git directory nor a gitdir file", gitDir)
+		}
+		gitDir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(repoRoot, gitDir)
+		}
+	}
+	commonDir, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if os.IsNotExist(err) {
+		return gitDir, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read git common directory: %w", err)
+	}
+	dir := strings.TrimSpace(string(commonDir))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return dir, nil
+}
+
+// coreExcludesFile returns the path of the core.excludesFile setting, from the repository's config or else the
+// user's, defaulting to $XDG_CONFIG_HOME/git/ignore like git does.
+func coreExcludesFile(commonDir string) string {
+	home, _ := os.UserHomeDir()
+	xdgConfigHome := os.Getenv("XDG_CONFIG_HOME")
+	if xdgConfigHome == "" && home != "" {
+		xdgConfigHome = filepath.Join(home, ".config")
+	}
+
+	configFiles := []string{filepath.Join(commonDir, "config")}
+	if home != "" {
+		configFiles = append(configFiles, filepath.Join(home, ".gitconfig"))
+	}
+	if xdgConfigHome != "" {
+		configFiles = append(configFiles, filepath.Join(xdgConfigHome, "git", "config"))
+	}
+	for _, configFile := range configFiles {
+		f, err := os.Open(configFile)
+		if err != nil {
+			continue
+		}
+		cfg := config.New()
+		err = config.NewDecoder(f).Decode(cfg)
+		f.Close()
+		if err != nil {
+			continue
+		}
+		if path := cfg.Section("core").Options.Get("excludesfile"); path != "" {
+			if strings.HasPrefix(path, "~/") && home != "" {
+				path = filepath.Join(home, path[2:])
+			}
+			return path
+		}
+	}
+
+	if xdgConfigHome == "" {
+		return ""
+	}
+	return filepath.Join(xdgConfigHome, "git", "ignore")
+}
+
+// readIgnoreFile parses the patterns of an ignore file, scoped to the directory domain. A missing file has no patterns.
+func readIgnoreFile(path string, domain []string) ([]gitignore.Pattern, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read ignore file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ignore file %q: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// dirPatterns returns the patterns of the .gitignore in the directory at the given path relative to repoRoot.
+func (m *ignoreMatcher) dirPatterns(dir []string) ([]gitignore.Pattern, error) {
+	key := strings.Join(dir, "/")
+	if patterns, ok := m.dirs[key]; ok {
+		return patterns, nil
+	}
+	path := filepath.Join(append([]string{m.repoRoot}, dir...)...)
+	patterns, err := readIgnoreFile(filepath.Join(path, ".gitignore"), dir)
+	if err != nil {
+		return nil, err
+	}
+	m.dirs[key] = patterns
+	return patterns, nil
+}
+
+// Match tells whether the path relative to the root directory is ignored. Tracked files, and directories containing
+// any, are not. Otherwise the last pattern that matches decides, with the .gitignore of deeper directories taking
+// precedence.
+func (m *ignoreMatcher) Match(rel string, isDir bool) (bool, error) {
+	path := append(append([]string{}, m.prefix...), strings.Split(filepath.ToSlash(rel), "/")...)
+	if key := strings.Join(path, "/"); m.tracked[key] || isDir && m.trackedDirs[key] {
+		return false, nil
+	}
+	for depth := len(path) - 1; depth >= 0; depth-- {
+		patterns, err := m.dirPatterns(path[:depth])
+		if err != nil {
+			return false, err
+		}
+		if result, ok := lastMatch(patterns, path, isDir); ok {
+			return result == gitignore.Exclude, nil
+		}
+	}
+	result, _ := lastMatch(m.global, path, isDir)
+	return result == gitignore.Exclude, nil
+}
+
+func lastMatch(patterns []gitignore.Pattern, path []string, isDir bool) (gitignore.MatchResult, bool) {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if result := patterns[i].Match(path, isDir); result != gitignore.NoMatch {
+			return result, true
+		}
+	}
+	return gitignore.NoMatch, false
+}
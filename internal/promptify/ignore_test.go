@@ -0,0 +1,149 @@
+package promptify
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func collectRel(t *testing.T, root string) []string {
+	t.Helper()
+	ign, err := newIgnoreMatcher(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := collectFiles(root, ign, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rel []string
+	for _, file := range files {
+		r, err := filepath.Rel(root, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	sort.Strings(rel)
+	return rel
+}
+
+func TestIgnoreMatcherKeepsTrackedFiles(t *testing.T) {
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, map[string]string{
+		".gitignore":         "*.log\nbuild/\n",
+		"main.go":            "package main\n",
+		"debug.log":          "untracked and ignored\n",
+		"keep.log":           "tracked although ignored\n",
+		"build/out.txt":      "untracked in an ignored directory\n",
+		"build/keep.txt":     "tracked in an ignored directory\n",
+		"sub/.gitignore":     "!important.log\n",
+		"sub/important.log":  "re-included by the nested .gitignore\n",
+		"sub/other.log":      "still ignored\n",
+		"sub/deeper/x.log":   "ignored by the root .gitignore\n",
+		"sub/deeper/code.go": "package deeper\n",
+	})
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tracked := range []string{"keep.log", "build/keep.txt"} {
+		if _, err := wt.Add(tracked); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		".gitignore",
+		"build/keep.txt",
+		"keep.log",
+		"main.go",
+		"sub/.gitignore",
+		"sub/deeper/code.go",
+		"sub/important.log",
+	}
+	if got := collectRel(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("collected %v, want %v", got, want)
+	}
+}
+
+func TestIgnoreMatcherOutsideRepository(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":  "*.tmp\n!keep.tmp\n",
+		"a.txt":       "a\n",
+		"b.tmp":       "b\n",
+		"keep.tmp":    "kept\n",
+		"dir/c.tmp":   "c\n",
+		"dir/d.txt":   "d\n",
+		"dir/.hidden": "hidden\n",
+	})
+
+	want := []string{".gitignore", "a.txt", "dir/.hidden", "dir/d.txt", "keep.tmp"}
+	if got := collectRel(t, root); !reflect.DeepEqual(got, want) {
+		t.Errorf("collected %v, want %v", got, want)
+	}
+}
+
+func TestIgnoreMatcherInWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is needed to create a worktree")
+	}
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = main
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	if err := os.Mkdir(main, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	writeFiles(t, main, map[string]string{
+		".gitignore":        "*.log\n",
+		".git/info/exclude": "*.secret\n",
+		"main.go":           "package main\n",
+		"tracked.log":       "tracked although ignored\n",
+	})
+	git("add", ".gitignore", "main.go")
+	git("add", "-f", "tracked.log")
+	git("commit", "-q", "-m", "initial")
+	worktree := filepath.Join(dir, "worktree")
+	git("worktree", "add", "-q", worktree)
+	writeFiles(t, worktree, map[string]string{
+		"new.txt":  "untracked\n",
+		"b.log":    "ignored by .gitignore\n",
+		"a.secret": "ignored by the main repository's info/exclude\n",
+	})
+
+	want := []string{".gitignore", "main.go", "new.txt", "tracked.log"}
+	if got := collectRel(t, worktree); !reflect.DeepEqual(got, want) {
+		t.Errorf("collected %v, want %v", got, want)
+	}
+}
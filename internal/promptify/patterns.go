@@ -0,0 +1,54 @@
+package promptify
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// validatePatterns checks the syntax of include or ignore patterns.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !doublestar.ValidatePattern(pattern) {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// matchesAny tells whether the path of a file or directory relative to the root matches one of the patterns.
+// Patterns with a slash, like "docs/*.md" or "src/**/*_test.go", are matched against the whole path, with "**"
+// matching any number of directories; patterns without one, like "*.md" or "node_modules", against the name only.
+func matchesAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		subject := rel
+		if !strings.Contains(pattern, "/") {
+			subject = path.Base(rel)
+		}
+		// patterns are validated beforehand
+		if matched, _ := doublestar.Match(pattern, subject); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedByPatterns tells whether a file, given by its path relative to the root, is kept by the include and ignore
+// patterns: it must match an include pattern, if there are any, and neither it nor any of its parent directories
+// may match an ignore pattern.
+func selectedByPatterns(rel string, includes []string, ignores []string) bool {
+	if len(includes) > 0 && !matchesAny(includes, rel) {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i := range parts {
+		if matchesAny(ignores, strings.Join(parts[:i+1], "/")) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package promptify
+
+import "testing"
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// patterns without a slash match the name, at any depth
+		{"*.md", "README.md", true},
+		{"*.md", "docs/guide/intro.md", true},
+		{"*.md", "docs/guide/intro.go", false},
+		{"node_modules", "web/node_modules", true},
+		{"node_modules", "web/node_modules_backup", false},
+		// patterns with a slash match the whole path
+		{"docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"docs/*.md", "other/docs/intro.md", false},
+		{"src/**/*_test.go", "src/a_test.go", true},
+		{"src/**/*_test.go", "src/pkg/deep/a_test.go", true},
+		{"src/**/*_test.go", "src/pkg/a.go", false},
+		{"**/testdata/**", "pkg/testdata/in/a.txt", true},
+		{"{cmd,pkg}/*.go", "pkg/a.go", true},
+		{"{cmd,pkg}/*.go", "internal/a.go", false},
+	}
+	for _, test := range tests {
+		if got := matchesAny([]string{test.pattern}, test.path); got != test.want {
+			t.Errorf("matchesAny(%q, %q) = %v, want %v", test.pattern, test.path, got, test.want)
+		}
+	}
+	if matchesAny(nil, "a.go") {
+		t.Error("no patterns matched a path")
+	}
+	if !matchesAny([]string{"*.md", "*.go"}, "a.go") {
+		t.Error("the second pattern wasn't tried")
+	}
+}
+
+func TestSelectedByPatterns(t *testing.T) {
+	tests := []struct {
+		path     string
+		includes []string
+		ignores  []string
+		want     bool
+	}{
+		{"a.go", nil, nil, true},
+		{"a.go", []string{"*.go"}, nil, true},
+		{"a.md", []string{"*.go"}, nil, false},
+		{"a_test.go", []string{"*.go"}, []string{"*_test.go"}, false},
+		// an ignored parent directory excludes everything under it, even what is included
+		{"vendor/lib/a.go", []string{"*.go"}, []string{"vendor"}, false},
+		{"pkg/vendor/a.go", nil, []string{"vendor"}, false},
+		{"docs/api/a.md", []string{"docs/**/*.md"}, []string{"docs/internal"}, true},
+		{"docs/internal/a.md", []string{"docs/**/*.md"}, []string{"docs/internal"}, false},
+	}
+	for _, test := range tests {
+		if got := selectedByPatterns(test.path, test.includes, test.ignores); got != test.want {
+			t.Errorf("selectedByPatterns(%q, %q, %q) = %v, want %v", test.path, test.includes, test.ignores, got, test.want)
+		}
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	if err := validatePatterns([]string{"*.go", "src/**/*.md", "{a,b}/c"}); err != nil {
+		t.Errorf("valid patterns were rejected: %v", err)
+	}
+	if err := validatePatterns([]string{"*.go", "[abc"}); err == nil {
+		t.Error("an unterminated character class was accepted")
+	}
+}
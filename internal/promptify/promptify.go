@@ -9,8 +9,6 @@ import (
 	"path/filepath"
 	"strings"
 	"text/template"
-
-	gitignore "github.com/sabhiram/go-gitignore"
 )
 
 // Options defines the configurable parameters for generating the prompt.
@@ -30,14 +28,50 @@ type Options struct {
 	//   "The contents below represent a directory '{{.Root}}' and its file contents..."
 	PromptIntro string
 
-	// IgnorePatterns is a list of file/directory name patterns that should be ignored
-	// in addition to the normal .gitignore (and the implicit .git folder).
-	// Patterns can be e.g. "*.md" or "node_modules".
+	// IncludePatterns, if set, restricts the prompt to the files matching one of these patterns.
+	// Patterns without a slash match file names, e.g. "*.go"; patterns with one match paths relative
+	// to RootDir, with "**" matching any number of directories, e.g. "**/*.go" or "docs/*.md".
+	IncludePatterns []string
+
+	// IgnorePatterns is a list of file/directory patterns that should be ignored
+	// in addition to the normal .gitignore (and the implicit .git folder), matched like IncludePatterns.
+	// Patterns can be e.g. "*.md", "node_modules" or "src/**/*_test.go". Ignore patterns win over include patterns.
 	IgnorePatterns []string
 
 	// DryRun, if true, indicates that we only want to list the files
-	// that would be included (skipping content retrieval and templating).
+	// that would be included (skipping content retrieval and templating, unless MaxTokens or CountTokens is set).
+	// Skipped binary files are listed after them.
 	DryRun bool
+
+	// BinaryMode decides whether binary files are skipped (the default), summarized or embedded as base64.
+	BinaryMode BinaryMode
+
+	// GitMode, if set, selects files from the git repository containing RootDir instead of walking it.
+	// MaxDepth, IncludePatterns and IgnorePatterns still apply, but ignore files don't.
+	GitMode GitMode
+
+	// GitRef is the ref (branch, tag or commit) that GitDiff compares with, e.g. "main".
+	GitRef string
+
+	// GitCommits is the number of commits whose files GitRecent selects.
+	GitCommits int
+
+	// MaxTokens is the maximum size of the prompt, in tokens as counted by Tokenizer.
+	// If <= 0, there is no limit.
+	MaxTokens int
+
+	// CountTokens renders the files even on a dry run without MaxTokens, so that Generate returns a report.
+	CountTokens bool
+
+	// Tokenizer counts tokens. If nil, ApproxTokenizer is used.
+	Tokenizer Tokenizer
+
+	// BudgetPolicy decides what happens to the files that don't fit in MaxTokens.
+	BudgetPolicy BudgetPolicy
+
+	// Priority ranks files when they don't all fit in MaxTokens; files with a higher priority are kept first.
+	// If nil, DefaultPriority is used.
+	Priority func(path string) int
 }
 
 // PromptifyData is the data used by the top-level PromptIntro template.
@@ -57,32 +91,65 @@ type FileData struct {
 // and additional ignore patterns. It also always ignores the .git folder by default.
 //
 // If opts.DryRun == true, then it simply returns a list of included file names
-// (one per line) without reading file contents or rendering templates.
+// (one per line) without reading file contents or rendering templates. With
+// opts.MaxTokens set, the files are rendered anyway and only those that fit are listed.
 func Promptify(opts Options) (string, error) {
+	prompt, _, err := Generate(opts)
+	return prompt, err
+}
+
+// Generate is like Promptify, and also returns a report of the token count of each file and of what happened to
+// the files that didn't fit in opts.MaxTokens. The report is nil for dry runs without a token budget, unless
+// opts.CountTokens is set.
+func Generate(opts Options) (string, *Report, error) {
 	if opts.RootDir == "" {
-		return "", errors.New("root directory must be specified")
+		return "", nil, errors.New("root directory must be specified")
 	}
 
-	// 1. Prepare the .gitignore matcher (ignore errors if .gitignore not found).
-	var ign *gitignore.GitIgnore
-	gitIgnorePath := filepath.Join(opts.RootDir, ".gitignore")
-	if fi, err := os.Stat(gitIgnorePath); err == nil && !fi.IsDir() {
-		ign, _ = gitignore.CompileIgnoreFile(gitIgnorePath)
+	if err := validatePatterns(append(append([]string{}, opts.IncludePatterns...), opts.IgnorePatterns...)); err != nil {
+		return "", nil, err
 	}
 
-	// 2. Collect all files up to max depth (if > 0), respecting .gitignore and user ignore patterns.
-	fileInfos, err := collectFiles(opts.RootDir, ign, opts.IgnorePatterns, opts.MaxDepth)
-	if err != nil {
-		return "", err
+	// 1. If a git mode is set, select the files from git (still up to max depth and respecting user patterns).
+	var fileInfos []string
+	var err error
+	if opts.GitMode != GitNone {
+		if fileInfos, err = gitFiles(opts.RootDir, opts); err != nil {
+			return "", nil, err
+		}
+	} else {
+		// 2. Otherwise, prepare the ignore matcher (.gitignore files, .git/info/exclude and core.excludesFile)
+		//    and collect all files up to max depth (if > 0), respecting it and user include and ignore patterns.
+		ign, err := newIgnoreMatcher(opts.RootDir)
+		if err != nil {
+			return "", nil, err
+		}
+		if fileInfos, err = collectFiles(opts.RootDir, ign, opts.IncludePatterns, opts.IgnorePatterns, opts.MaxDepth); err != nil {
+			return "", nil, err
+		}
 	}
 
-	// 3. If we're just doing a dry run, return the filenames only.
-	if opts.DryRun {
-		var buf bytes.Buffer
-		for _, path := range fileInfos {
-			buf.WriteString(path + "\n")
+	// 3. Sniff the files to find the binary ones.
+	sniffed := make([]sniffedFile, len(fileInfos))
+	var skipped []sniffedFile
+	for i, path := range fileInfos {
+		if sniffed[i], err = sniffFile(path); err != nil {
+			return "", nil, err
+		}
+		if sniffed[i].binary && opts.BinaryMode == SkipBinary {
+			skipped = append(skipped, sniffed[i])
 		}
-		return buf.String(), nil
+	}
+
+	// 3a. If we're just doing a dry run without tokens to count, return the filenames only.
+	if opts.DryRun && opts.MaxTokens <= 0 && !opts.CountTokens {
+		var included []string
+		for _, file := range sniffed {
+			if !file.binary || opts.BinaryMode != SkipBinary {
+				included = append(included, file.path)
+			}
+		}
+		return fileList(included) + skippedList(skipped), nil, nil
 	}
 
 	// 4. Otherwise, parse templates and render the actual prompt.
@@ -90,59 +157,120 @@ func Promptify(opts Options) (string, error) {
 	// 4a. Parse the introduction template.
 	introTmpl, err := template.New("intro").Parse(opts.PromptIntro)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse PromptIntro template: %w", err)
+		return "", nil, fmt.Errorf("failed to parse PromptIntro template: %w", err)
 	}
 
 	// 4b. Parse the file format template.
 	fileTmpl, err := template.New("fileFormat").Parse(opts.FileFormat)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse FileFormat template: %w", err)
+		return "", nil, fmt.Errorf("failed to parse FileFormat template: %w", err)
 	}
 
-	// 5. Build the result in a string buffer.
-	var buf bytes.Buffer
+	tokenizer := opts.Tokenizer
+	if tokenizer == nil {
+		tokenizer = ApproxTokenizer{}
+	}
 
-	// 5a. Render the intro template first.
+	// 5. Render the intro template first.
+	var intro bytes.Buffer
 	introData := PromptifyData{
 		Root:       opts.RootDir,
 		FileFormat: opts.FileFormat,
 	}
-	if err := introTmpl.Execute(&buf, introData); err != nil {
-		return "", fmt.Errorf("failed to execute PromptIntro template: %w", err)
+	if err := introTmpl.Execute(&intro, introData); err != nil {
+		return "", nil, fmt.Errorf("failed to execute PromptIntro template: %w", err)
 	}
-	if !strings.HasSuffix(buf.String(), "\n") {
-		buf.WriteString("\n")
+	if !strings.HasSuffix(intro.String(), "\n") {
+		intro.WriteString("\n")
 	}
-	buf.WriteString("\n")
-
-	// 5b. For each file, read contents & apply file template.
-	for _, path := range fileInfos {
+	intro.WriteString("\n")
+	report := &Report{IntroTokens: tokenizer.CountTokens(intro.String()), MaxTokens: opts.MaxTokens}
+
+	// 6. For each file, read contents, apply file template & count tokens.
+	files := make([]*renderedFile, len(fileInfos))
+	for i, path := range fileInfos {
+		if sniffed[i].binary && opts.BinaryMode == SkipBinary {
+			files[i] = &renderedFile{path: path, mimeType: sniffed[i].mimeType, skipped: true}
+			continue
+		}
 		content, err := os.ReadFile(path)
 		if err != nil {
-			return "", fmt.Errorf("failed to read file %q: %w", path, err)
+			return "", nil, fmt.Errorf("failed to read file %q: %w", path, err)
 		}
-
-		fileData := FileData{
-			FileName: path,
-			Content:  string(content),
+		file := &renderedFile{path: path, content: string(content)}
+		if sniffed[i].binary {
+			file.mimeType = sniffed[i].mimeType
+			file.content = binaryContent(opts.BinaryMode, sniffed[i], content)
+		}
+		if file.text, err = renderFile(fileTmpl, path, file.content); err != nil {
+			return "", nil, err
 		}
+		file.tokens = tokenizer.CountTokens(file.text)
+		files[i] = file
+	}
 
-		if err := fileTmpl.Execute(&buf, fileData); err != nil {
-			return "", fmt.Errorf("failed to execute FileFormat template for file %q: %w", path, err)
+	// 7. Drop or truncate the files that don't fit in the budget.
+	report.Files, err = applyBudget(files, opts.MaxTokens-report.IntroTokens, opts, tokenizer, fileTmpl)
+	if err != nil {
+		return "", nil, err
+	}
+	report.TotalTokens = report.IntroTokens
+	for _, file := range report.Files {
+		report.TotalTokens += file.IncludedTokens
+	}
+
+	// 8. Build the result in a string buffer.
+	if opts.DryRun {
+		var included []string
+		for i, file := range report.Files {
+			if file.Status == FileIncluded || file.Status == FileTruncated {
+				included = append(included, fileInfos[i])
+			}
 		}
-		if !strings.HasSuffix(buf.String(), "\n") {
-			buf.WriteString("\n")
+		return fileList(included) + skippedList(skipped), report, nil
+	}
+	var buf bytes.Buffer
+	buf.Write(intro.Bytes())
+	for i, file := range files {
+		if status := report.Files[i].Status; status == FileIncluded || status == FileTruncated {
+			buf.WriteString(file.text)
 		}
-		buf.WriteString("\n")
 	}
 
+	return buf.String(), report, nil
+}
+
+// fileList returns the file names, one per line.
+func fileList(paths []string) string {
+	var buf bytes.Buffer
+	for _, path := range paths {
+		buf.WriteString(path + "\n")
+	}
+	return buf.String()
+}
+
+// renderFile applies the file format template to a file, followed by a blank line.
+func renderFile(fileTmpl *template.Template, path string, content string) (string, error) {
+	var buf bytes.Buffer
+	fileData := FileData{
+		FileName: path,
+		Content:  content,
+	}
+	if err := fileTmpl.Execute(&buf, fileData); err != nil {
+		return "", fmt.Errorf("failed to execute FileFormat template for file %q: %w", path, err)
+	}
+	if !strings.HasSuffix(buf.String(), "\n") {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
 	return buf.String(), nil
 }
 
 // collectFiles walks through the directory up to maxDepth (if > 0),
-// collecting files that are *not* ignored by the .gitignore parser (if provided),
-// and are not matched by any custom ignore patterns. Also implicitly ignores the .git folder.
-func collectFiles(root string, ign *gitignore.GitIgnore, userIgnores []string, maxDepth int) ([]string, error) {
+// collecting files that are *not* ignored by git's ignore rules,
+// match the custom include patterns (if any), and are not matched by any custom ignore patterns.
+// Also implicitly ignores the .git folder.
+func collectFiles(root string, ign *ignoreMatcher, userIncludes []string, userIgnores []string, maxDepth int) ([]string, error) {
 	var result []string
 
 	err := filepath.Walk(root, func(path string, info os.FileInfo, walkErr error) error {
@@ -155,9 +283,12 @@ func collectFiles(root string, ign *gitignore.GitIgnore, userIgnores []string, m
 			return nil
 		}
 
-		// If this is the .git folder, skip it (and everything inside).
-		if info.IsDir() && info.Name() == ".git" {
-			return filepath.SkipDir
+		// If this is the .git folder, skip it (and everything inside). In worktrees and submodules it is a file.
+		if info.Name() == ".git" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
 		// Compute depth by counting separators in the relative path
@@ -176,7 +307,11 @@ func collectFiles(root string, ign *gitignore.GitIgnore, userIgnores []string, m
 		}
 
 		// 1) .gitignore check
-		if ign != nil && ign.MatchesPath(rel) {
+		ignored, err := ign.Match(rel, info.IsDir())
+		if err != nil {
+			return err
+		}
+		if ignored {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -184,19 +319,12 @@ func collectFiles(root string, ign *gitignore.GitIgnore, userIgnores []string, m
 		}
 
 		// 2) user-specified ignore patterns
-		for _, pattern := range userIgnores {
-			matched, matchErr := filepath.Match(pattern, info.Name())
-			if matchErr != nil {
-				// If the pattern is invalid, skip or handle as needed
-				continue
-			}
-			if matched {
-				// If the name matches the pattern, skip this file/dir
-				if info.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
+		if matchesAny(userIgnores, rel) {
+			// If the path matches the pattern, skip this file/dir
+			if info.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
 		}
 
 		// If we're a directory, keep walking
@@ -204,6 +332,11 @@ func collectFiles(root string, ign *gitignore.GitIgnore, userIgnores []string, m
 			return nil
 		}
 
+		// 3) user-specified include patterns, for files only
+		if len(userIncludes) > 0 && !matchesAny(userIncludes, rel) {
+			return nil
+		}
+
 		// If we're a file, add it
 		result = append(result, path)
 		return nil
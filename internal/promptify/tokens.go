@@ -0,0 +1,69 @@
+package promptify
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a language model would see in a piece of text.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// ApproxTokenizer estimates token counts offline, in the way BPE tokenizers such as OpenAI's cl100k split text:
+// the text is pre-split into words (with their leading space), numbers, punctuation and whitespace, and each piece
+// is assumed to be made of roughly 4-letter or 3-digit tokens. It is usually within 10-20% of the real count for
+// English text and source code.
+type ApproxTokenizer struct{}
+
+func (ApproxTokenizer) CountTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case r == '\n':
+			// runs of newlines are merged into a single token
+			for i < len(text) && text[i] == '\n' {
+				i++
+			}
+			tokens++
+		case r == ' ' || r == '\t':
+			// a single space is merged into the word after it, longer runs of indentation are a token of their own
+			start := i
+			for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+				i++
+			}
+			if i-start > 1 || i == len(text) {
+				tokens++
+			}
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			start := i
+			for i < len(text) && text[i] < utf8.RuneSelf && unicode.IsLetter(rune(text[i])) {
+				i++
+			}
+			tokens += (i - start + 3) / 4
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+				i++
+			}
+			if i == start {
+				// a non-ASCII digit
+				i += size
+			}
+			tokens += (i - start + 2) / 3
+		case r >= utf8.RuneSelf:
+			// non-ASCII characters (accents, CJK, emoji) mostly take a token each
+			i += size
+			tokens++
+		default:
+			// punctuation and symbols are a token each, except for common pairs like "==" or "//"
+			i += size
+			if i < len(text) && text[i] == byte(r) {
+				i++
+			}
+			tokens++
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,28 @@
+package promptify
+
+import "testing"
+
+func TestApproxTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello", 2},
+		{"hi there", 3},
+		{"end ", 2},
+		{"x  = 1", 4},
+		{"12345", 2},
+		{"a\n\n\nb", 3},
+		{"==", 1},
+		{"!=", 2},
+		{"日本", 2},
+		{"// comment", 3},
+		{"\tif err != nil {", 6},
+	}
+	for _, test := range tests {
+		if got := (ApproxTokenizer{}).CountTokens(test.text); got != test.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+)
+
+// ErrStderr is the reason of an *Error caused by the command's stderr rather than its exit status.
+var ErrStderr = errors.New("stderr indicates an error")
+
+// stderrTailBytes is how much of the end of stderr is kept in an *Error.
+const stderrTailBytes = 1024
+
+// Error is the error of a command that ran but was classified as failed by its ErrorPolicy.
+type Error struct {
+	Argv     []string
+	ExitCode int
+	// StderrTail is the end of the command's stderr.
+	StderrTail string
+	// Err is the underlying reason: an *exec.ExitError, a *StoppedError, or ErrStderr.
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("command %s failed: %v", Quote(e.Argv...), e.Err)
+	var exitErr *exec.ExitError
+	if e.ExitCode > 0 && !errors.As(e.Err, &exitErr) {
+		msg += fmt.Sprintf(" (exit code %d)", e.ExitCode)
+	}
+	if e.StderrTail != "" {
+		msg += fmt.Sprintf(", stderr: %s", e.StderrTail)
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPolicy decides whether a command that ran to completion failed.
+// By default, only a non-zero exit code (or being stopped or killed by a signal) is an error.
+type ErrorPolicy struct {
+	// AllowedExitCodes are non-zero exit codes that don't count as errors.
+	AllowedExitCodes []int
+	// StderrErrorPatterns are patterns that make the command fail if any line of stderr matches one of them.
+	StderrErrorPatterns []*regexp.Regexp
+	// StderrIgnorePatterns are patterns for stderr lines that are never considered errors, e.g. progress or warnings.
+	// They take precedence over StderrErrorPatterns and AnyStderrIsError.
+	StderrIgnorePatterns []*regexp.Regexp
+	// AnyStderrIsError makes the command fail if it writes anything to stderr (apart from ignored lines).
+	AnyStderrIsError bool
+}
+
+// StderrAsErrorPolicy is the policy set by WithTreatStderrAsErr(true): any stderr output is an error.
+func StderrAsErrorPolicy() ErrorPolicy {
+	return ErrorPolicy{AnyStderrIsError: true}
+}
+
+// WithErrorPolicy sets how the command's exit code and stderr are classified into Result.Err.
+func (c *Command) WithErrorPolicy(policy ErrorPolicy) *Command {
+	c.errorPolicy = policy
+	return c
+}
+
+/*
+WithTreatStderrAsErr makes any output on stderr an error, even if the command exited successfully.
+It is a preset for WithErrorPolicy(StderrAsErrorPolicy()); passing false restores the default policy.
+*/
+func (c *Command) WithTreatStderrAsErr(treatStderAsErr bool) *Command {
+	if treatStderAsErr {
+		return c.WithErrorPolicy(StderrAsErrorPolicy())
+	}
+	return c.WithErrorPolicy(ErrorPolicy{})
+}
+
+// classify applies the error policy to the result of the finished process, wrapping any failure in an *Error.
+func (c *Command) classify() {
+	err := c.Result.Err
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && !c.Result.Signaled {
+		for _, code := range c.errorPolicy.AllowedExitCodes {
+			if exitErr.ExitCode() == code {
+				err = nil
+				break
+			}
+		}
+	}
+	if err == nil && c.errorPolicy.stderrIsError(c.Result.Stderr.Bytes()) {
+		err = ErrStderr
+	}
+	if err == nil {
+		c.Result.Err = nil
+		return
+	}
+	stderr := c.Result.Stderr.Bytes()
+	if len(stderr) > stderrTailBytes {
+		stderr = stderr[len(stderr)-stderrTailBytes:]
+	}
+	c.Result.Err = &Error{
+		Argv:       c.loggedArgv(),
+		ExitCode:   c.Result.ExitCode,
+		StderrTail: string(bytes.TrimSpace(stderr)),
+		Err:        err,
+	}
+}
+
+func (p *ErrorPolicy) stderrIsError(stderr []byte) bool {
+	if !p.AnyStderrIsError && len(p.StderrErrorPatterns) == 0 {
+		return false
+	}
+	for _, line := range bytes.Split(stderr, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 || matchesAny(p.StderrIgnorePatterns, line) {
+			continue
+		}
+		if p.AnyStderrIsError || matchesAny(p.StderrErrorPatterns, line) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []*regexp.Regexp, line []byte) bool {
+	for _, pattern := range patterns {
+		if pattern.Match(line) {
+			return true
+		}
+	}
+	return false
+}
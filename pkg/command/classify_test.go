@@ -0,0 +1,133 @@
+package command
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestStderrIsError(t *testing.T) {
+	errPattern := []*regexp.Regexp{regexp.MustCompile(`(?i)error`)}
+	ignorePattern := []*regexp.Regexp{regexp.MustCompile(`^warning:`)}
+	tests := []struct {
+		name   string
+		policy ErrorPolicy
+		stderr string
+		want   bool
+	}{
+		{"default policy ignores stderr", ErrorPolicy{}, "error: boom\n", false},
+		{"any stderr", ErrorPolicy{AnyStderrIsError: true}, "something\n", true},
+		{"any stderr, but empty", ErrorPolicy{AnyStderrIsError: true}, "", false},
+		{"any stderr, but blank lines only", ErrorPolicy{AnyStderrIsError: true}, "\n  \n\t\n", false},
+		{"any stderr, all lines ignored", ErrorPolicy{AnyStderrIsError: true, StderrIgnorePatterns: ignorePattern},
+			"warning: deprecated\nwarning: slow\n", false},
+		{"any stderr, one line not ignored", ErrorPolicy{AnyStderrIsError: true, StderrIgnorePatterns: ignorePattern},
+			"warning: deprecated\nsomething else\n", true},
+		{"error pattern matches", ErrorPolicy{StderrErrorPatterns: errPattern}, "progress 50%\nERROR: boom\n", true},
+		{"error pattern doesn't match", ErrorPolicy{StderrErrorPatterns: errPattern}, "progress 50%\ndone\n", false},
+		{"ignore pattern wins over error pattern", ErrorPolicy{StderrErrorPatterns: errPattern, StderrIgnorePatterns: ignorePattern},
+			"warning: error budget almost used\n", false},
+		{"ignore pattern only covers its own lines", ErrorPolicy{StderrErrorPatterns: errPattern, StderrIgnorePatterns: ignorePattern},
+			"warning: error budget almost used\nerror: out of budget\n", true},
+		{"last line without newline", ErrorPolicy{StderrErrorPatterns: errPattern}, "ok\nerror", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.stderrIsError([]byte(tt.stderr)); got != tt.want {
+				t.Errorf("stderrIsError(%q) = %v, want %v", tt.stderr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		policy ErrorPolicy
+		// wantErr is the reason the *Error wraps, nil if the command should succeed
+		wantErr  error
+		exitErr  bool
+		exitCode int
+	}{
+		{name: "success", script: "exit 0"},
+		{name: "non-zero exit code", script: "exit 3", exitErr: true, exitCode: 3},
+		{name: "allowed exit code", script: "exit 3", policy: ErrorPolicy{AllowedExitCodes: []int{1, 3}}, exitCode: 3},
+		{name: "other exit code than the allowed ones", script: "exit 3",
+			policy: ErrorPolicy{AllowedExitCodes: []int{1}}, exitErr: true, exitCode: 3},
+		{name: "allowed exit code, but stderr is an error", script: "echo fatal >&2; exit 3",
+			policy:  ErrorPolicy{AllowedExitCodes: []int{3}, StderrErrorPatterns: []*regexp.Regexp{regexp.MustCompile("fatal")}},
+			wantErr: ErrStderr, exitCode: 3},
+		{name: "stderr is an error on success", script: "echo oops >&2",
+			policy: StderrAsErrorPolicy(), wantErr: ErrStderr},
+		{name: "ignored stderr on success", script: "echo 'warning: oops' >&2",
+			policy: ErrorPolicy{AnyStderrIsError: true, StderrIgnorePatterns: []*regexp.Regexp{regexp.MustCompile("^warning:")}}},
+		{name: "killed by a signal is never allowed", script: "kill -9 $$",
+			policy: ErrorPolicy{AllowedExitCodes: []int{-1, 137}}, exitErr: true, exitCode: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := New("sh", "-c", tt.script).WithErrorPolicy(tt.policy).Run()
+			if res.ExitCode != tt.exitCode {
+				t.Errorf("exit code %d, want %d", res.ExitCode, tt.exitCode)
+			}
+			if tt.wantErr == nil && !tt.exitErr {
+				if res.Err != nil {
+					t.Errorf("unexpected error %v", res.Err)
+				}
+				return
+			}
+			var cmdErr *Error
+			if !errors.As(res.Err, &cmdErr) {
+				t.Fatalf("got %v (%T), want an *Error", res.Err, res.Err)
+			}
+			if got := strings.Join(cmdErr.Argv, " "); got != "sh -c "+tt.script {
+				t.Errorf("argv %q", got)
+			}
+			if cmdErr.ExitCode != tt.exitCode {
+				t.Errorf("*Error exit code %d, want %d", cmdErr.ExitCode, tt.exitCode)
+			}
+			var exitErr *exec.ExitError
+			if got := errors.As(res.Err, &exitErr); got != tt.exitErr {
+				t.Errorf("wraps an *exec.ExitError: %v, want %v (%v)", got, tt.exitErr, res.Err)
+			}
+			if tt.wantErr != nil && !errors.Is(res.Err, tt.wantErr) {
+				t.Errorf("got %v, want it to wrap %v", res.Err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	long := strings.Repeat("x", 2*stderrTailBytes)
+	res := New("sh", "-c", "echo "+long+" >&2; echo '  the end  ' >&2").WithTreatStderrAsErr(true).Run()
+	var cmdErr *Error
+	if !errors.As(res.Err, &cmdErr) {
+		t.Fatalf("got %v, want an *Error", res.Err)
+	}
+	if len(cmdErr.StderrTail) > stderrTailBytes || !strings.HasSuffix(cmdErr.StderrTail, "the end") {
+		t.Errorf("stderr tail of %d bytes ending in %q, want at most %d bytes ending in the last line",
+			len(cmdErr.StderrTail), cmdErr.StderrTail[len(cmdErr.StderrTail)-10:], stderrTailBytes)
+	}
+	msg := cmdErr.Error()
+	if !strings.HasPrefix(msg, "command sh -c ") || !strings.Contains(msg, "failed: "+ErrStderr.Error()) ||
+		!strings.HasSuffix(msg, "the end") || strings.Contains(msg, "exit code") {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	res = New("sh", "-c", "exit 2").Run()
+	if !errors.As(res.Err, &cmdErr) {
+		t.Fatalf("got %v, want an *Error", res.Err)
+	}
+	// the exit code is already part of the *exec.ExitError message, so it isn't repeated
+	if msg := cmdErr.Error(); msg != "command sh -c 'exit 2' failed: exit status 2" {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	cmdErr = &Error{Argv: []string{"tool"}, ExitCode: 4, Err: ErrStderr, StderrTail: "bad"}
+	if msg := cmdErr.Error(); msg != "command tool failed: stderr indicates an error (exit code 4), stderr: bad" {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
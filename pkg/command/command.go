@@ -2,19 +2,92 @@ package command
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// ErrTimeout and ErrCanceled are the reasons reported by a StoppedError.
+var (
+	ErrTimeout  = errors.New("command timed out")
+	ErrCanceled = errors.New("command canceled")
+)
+
+// ErrOutputAbandoned is reported when the output of a stopped command was still held open by processes it left
+// behind once the grace period was over, so that what they wrote after that was not collected.
+var ErrOutputAbandoned = errors.New("output collection abandoned after the grace period")
+
+// ErrAlreadyRunning is returned when starting a command that is still running.
+var ErrAlreadyRunning = errors.New("command is already running")
+
+// defaultGracePeriod is how long a stopped command has to exit after SIGINT before it is sent SIGKILL.
+const defaultGracePeriod = 2 * time.Second
+
+// StoppedError is set as Result.Err when a command is stopped because its context is done
+// (either canceled or its deadline / WithTimeout elapsed) before it exited on its own.
+type StoppedError struct {
+	// Reason is ErrTimeout or ErrCanceled.
+	Reason error
+	// Killed is true if the process did not exit within the grace period after SIGINT and had to be sent SIGKILL.
+	Killed bool
+	// AlivePids are the processes that were still alive when SIGKILL was sent.
+	AlivePids []int
+	// Err is the error returned by waiting on the process after it was signaled, if any. It wraps ErrOutputAbandoned if
+	// processes left behind kept the output open past the grace period.
+	Err error
+}
+
+func (e *StoppedError) Error() string {
+	msg := e.Reason.Error()
+	if e.Killed {
+		msg += " (killed after grace period)"
+		if len(e.AlivePids) > 0 {
+			msg += fmt.Sprintf(" (still alive: %v)", e.AlivePids)
+		}
+	} else {
+		msg += " (interrupted)"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *StoppedError) Unwrap() []error {
+	return []error{e.Reason, e.Err}
+}
+
 type Result struct {
 	Stdout bytes.Buffer
 	Stderr bytes.Buffer
 	Err    error
+	// ExitCode is the exit code of the process, or -1 if it didn't start or was terminated by a signal.
+	ExitCode int
+	// Signaled is true if the process was terminated by a signal, which is then stored in Signal.
+	Signaled bool
+	Signal   syscall.Signal
+	// Duration is the wall-clock time from starting the process until it was waited on.
+	Duration time.Duration
+	// UserTime and SystemTime are the CPU time the process spent in user and kernel mode.
+	UserTime   time.Duration
+	SystemTime time.Duration
+	// MaxRSS is the maximum resident set size of the process in bytes. It is only reported on Unix.
+	MaxRSS int64
+	// StdoutTruncated and StderrTruncated are true if output was dropped because of WithStdoutLimit or WithStderrLimit.
+	StdoutTruncated bool
+	StderrTruncated bool
+	// StdoutFile and StderrFile are the paths of the files the complete output was spilled to, if OutputLimit.Spill was set.
+	StdoutFile string
+	StderrFile string
+	// Attempts holds the results of the earlier, failed attempts when the command was run WithRetry.
+	Attempts []*Result
 }
 
 type Command struct {
@@ -22,86 +95,138 @@ type Command struct {
 	executable       string
 	args             []string
 	verbose          bool
-	treatStderrAsErr bool
+	errorPolicy      ErrorPolicy
 	dir              string
 	attachToTerminal bool
+	timeout          time.Duration
+	gracePeriod      time.Duration
+	processGroup     bool
+	session          bool
+	stdoutHandlers   []LineHandler
+	stderrHandlers   []LineHandler
+	stdoutWriters    []io.Writer
+	stderrWriters    []io.Writer
+	env              map[string]string
+	unsetEnv         map[string]bool
+	cleanEnv         bool
+	stdin            io.Reader
+	stdinString      *string
+	stdinFile        string
+	retry            *RetryPolicy
+	pty              bool
+	ptyTranscript    io.Writer
+	logger           Logger
+	runner           Runner
+	redactors        []Redactor
+	stdoutLimit      *OutputLimit
+	stderrLimit      *OutputLimit
+	rlimits          *ResourceLimits
+	nice             *int
+	ioPriority       *ioPriority
+	cgroup           *CgroupLimits
+	uid              *uint32
+	gid              uint32
+	groups           []uint32
+	username         string
+	chroot           string
+	cloneflags       uintptr
 	cmd              *exec.Cmd
-	killRequest      chan error
+	// stopping is closed by markStopping once the process is signaled by stop or Kill, from then on collecting its
+	// output is bounded by the grace period
+	stopping     chan struct{}
+	markStopping func()
+	// stopGracePeriod is the grace period of the current run: the command's own, or the one of its pipeline
+	stopGracePeriod time.Duration
+	release         func()
+	started         time.Time
+	// exited is closed once the process has exited, and done receives the error of waiting on it once its output
+	// has been collected too
+	exited chan struct{}
+	done   chan error
+	// mu guards cmd, running and finished, which are accessed concurrently by Pid, Kill, Done and Wait
+	mu       sync.Mutex
+	running  bool
+	finished chan struct{}
 }
 
+// Pid returns the pid of the running (or finished) process, or 0 if it hasn't been started.
 func (c *Command) Pid() int {
-	if c.cmd.Process != nil {
-		return c.cmd.Process.Pid
+	if proc := c.process(); proc != nil {
+		return proc.Pid
 	}
 	return 0
 }
 
-func (c *Command) doesProcessExist() (bool, error) {
-	if c.cmd.Process == nil {
-		return false, errors.New("command hasn't run yet")
+func (c *Command) process() *os.Process {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cmd == nil {
+		return nil
 	}
+	return c.cmd.Process
+}
+
+func (c *Command) doesProcessExist() (bool, error) {
 	if c.Pid() == 0 {
 		return false, errors.New("command hasn't run yet")
 	}
 	// from the docs: On Unix systems, FindProcess always succeeds and returns a Process
 	// so we can't use os.FindProcess(c.Pid())
-	proc, err := os.FindProcess(c.Pid())
-	if err != nil {
-		return false, fmt.Errorf("could not find process: %w", err)
-	}
-	// check if process exists
-	err = proc.Signal(syscall.Signal(0))
+	// check if process (or any process in its group) exists
+	err := c.signal(syscall.Signal(0))
 	return err == nil, nil
 }
 
-func (c *Command) doKill(timeout int) {
-	// send os.Interrupt, if after timeout milliseconds, the proc is still alive, run c.cmd.Process.Kill()
-	proc, err := os.FindProcess(c.Pid())
-	if err != nil {
-		c.killRequest <- fmt.Errorf("could not find proc: %w", err)
-		return
-	}
-	interruptResult := proc.Signal(os.Interrupt)
+func (c *Command) doKill(timeout int, killRequest chan<- error) {
+	// send SIGINT, if after timeout milliseconds, the proc (or its group) is still alive, send SIGKILL
+	interruptResult := c.signal(syscall.SIGINT)
 	if interruptResult != nil {
-		c.killRequest <- fmt.Errorf("failed to send interrupt signal to proc: %w", interruptResult)
+		killRequest <- fmt.Errorf("failed to send interrupt signal to proc: %w", interruptResult)
 		return
 	}
 	start := time.Now()
-	ticker := time.NewTicker(100)
+	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
+	done := c.Done()
 	for {
 		select {
-		case <-ticker.C:
-			processExists, err := c.doesProcessExist()
-			if err != nil {
-				c.killRequest <- fmt.Errorf("could not check if process exists: %w", err)
-				return
-			}
-			if !processExists {
-				c.killRequest <- nil
+		case <-done:
+			if !c.ownsGroup() {
+				killRequest <- nil
 				return
 			}
+			// the rest of the group is polled on the ticker from now on
+			done = nil
+		case <-ticker.C:
+		}
+		processExists, err := c.doesProcessExist()
+		if err != nil {
+			killRequest <- fmt.Errorf("could not check if process exists: %w", err)
+			return
+		}
+		if !processExists {
+			killRequest <- nil
+			return
 		}
 		if time.Since(start) > time.Duration(timeout)*time.Millisecond {
 			break
 		}
 	}
-	errorMessage := fmt.Sprintf("proc did not exit after %d milliseconds, used Process.Kill to terminate it", timeout)
-	killErr := c.cmd.Process.Kill()
-	if killErr != nil {
-		c.killRequest <- fmt.Errorf(errorMessage+", and then an additional error occured: %w", killErr)
-		return
+	killRequest <- &EscalationError{
+		AlivePids: c.alivePids(),
+		Err:       c.signal(syscall.SIGKILL),
+		msg:       fmt.Sprintf("proc did not exit after %d milliseconds, used SIGKILL to terminate it", timeout),
 	}
-	c.killRequest <- errors.New(errorMessage)
-	return
 }
 
 /*
 Kill sends an SIGINT signal to the process, and if after timeout milliseconds,
 the process is still alive, kills the process using SIGKILL.
+If the command was started with WithProcessGroup or WithSession, the whole process group is signaled,
+and the returned *EscalationError lists the pids that were still alive when SIGKILL was sent.
 */
 func (c *Command) Kill(timeout int) error {
-	if c.cmd == nil || c.cmd.Process == nil {
+	if c.process() == nil {
 		return errors.New("command hasn't run yet")
 	}
 	processExists, err := c.doesProcessExist()
@@ -111,9 +236,11 @@ func (c *Command) Kill(timeout int) error {
 	if !processExists {
 		return nil
 	}
-	c.killRequest = make(chan error, 1)
-	go c.doKill(timeout)
-	return <-c.killRequest
+	// like a timeout, Kill bounds how long output held open by processes left behind is collected
+	c.markStopping()
+	killRequest := make(chan error, 1)
+	go c.doKill(timeout, killRequest)
+	return <-killRequest
 }
 
 func New(executable string, args ...string) *Command {
@@ -123,8 +250,8 @@ func New(executable string, args ...string) *Command {
 		executable:       executable,
 		args:             argsAsArray,
 		verbose:          true,
-		treatStderrAsErr: false,
 		attachToTerminal: false,
+		gracePeriod:      defaultGracePeriod,
 	}
 	c.Result = &Result{
 		Stdout: bytes.Buffer{},
@@ -139,75 +266,416 @@ func (c *Command) WithVerbose(verbose bool) *Command {
 	return c
 }
 
-func (c *Command) WithTreatStderrAsErr(treatStderAsErr bool) *Command {
-	c.treatStderrAsErr = treatStderAsErr
-	return c
-}
-
 func (c *Command) WithDir(dir string) *Command {
 	c.dir = dir
 	return c
 }
 
+// WithAttachToTerminal connects the command's stdin, stdout and stderr to the ones of the current process, instead
+// of capturing its output in the Result.
 func (c *Command) WithAttachToTerminal(attach bool) *Command {
 	c.attachToTerminal = attach
 	return c
 }
 
+// WithTimeout stops the command if it is still running after timeout. A timeout <= 0 means no timeout.
+func (c *Command) WithTimeout(timeout time.Duration) *Command {
+	c.timeout = timeout
+	return c
+}
+
+/*
+WithGracePeriod sets how long a stopped command is given to exit after SIGINT before it is sent SIGKILL.
+It also bounds how long output is collected once a stopped command has exited or been killed, since processes it left
+behind (e.g. the children of a shell) may hold its stdout and stderr open; if that cuts the output short, the error
+wraps ErrOutputAbandoned. The output of a command that exits on its own is always collected in full.
+*/
+func (c *Command) WithGracePeriod(gracePeriod time.Duration) *Command {
+	c.gracePeriod = gracePeriod
+	return c
+}
+
 func (c *Command) Run() *Result {
-	if c.verbose {
-		if c.dir == "" {
-			fmt.Printf("Running command: %v %v\n", c.executable, strings.Join(c.args, " "))
-		} else {
-			fmt.Printf("Running command (cwd=%s) : %v %v\n", c.dir, c.executable, strings.Join(c.args, " "))
-		}
+	return c.RunContext(context.Background())
+}
+
+/*
+RunContext runs the command and waits for it to finish. If ctx is done (or the timeout set by WithTimeout elapses)
+before the command exits, the process is sent SIGINT, and if it is still alive after the grace period, SIGKILL.
+In that case Result.Err is a *StoppedError whose Reason is ErrTimeout or ErrCanceled.
+*/
+func (c *Command) RunContext(ctx context.Context) *Result {
+	if c.retry != nil {
+		return c.runWithRetry(ctx)
+	}
+	if err := c.StartContext(ctx); errors.Is(err, ErrAlreadyRunning) {
+		return &Result{Err: err, ExitCode: -1}
+	}
+	return c.Wait()
+}
+
+// Start starts the command in the background without waiting for it to finish. Use Wait or Done to learn when it exits.
+func (c *Command) Start() error {
+	return c.StartContext(context.Background())
+}
+
+/*
+StartContext starts the command in the background. The process is stopped as described in RunContext if ctx is done
+(or the timeout set by WithTimeout elapses) before it exits. If starting fails, the error is also recorded in the
+Result returned by Wait.
+
+If the command uses a Runner other than RealRunner (see WithRunner and SetDefaultRunner), the runner is invoked in
+the background instead, and its errors are only reported through Wait.
+*/
+func (c *Command) StartContext(ctx context.Context) error {
+	if err := c.begin(); err != nil {
+		return err
+	}
+	if runner := c.activeRunner(); !isRealRunner(runner) {
+		go func() {
+			res := runner.Run(ctx, c)
+			if res != nil {
+				c.Result = res
+			}
+			c.end()
+		}()
+		return nil
+	}
+	if err := c.launch(); err != nil {
+		c.fail(err)
+		c.end()
+		return err
+	}
+	go func() {
+		c.wait(ctx)
+		c.end()
+	}()
+	return nil
+}
+
+// Wait waits for a started command to finish and returns its result.
+func (c *Command) Wait() *Result {
+	done := c.Done()
+	if done == nil {
+		return &Result{Err: errors.New("command hasn't been started"), ExitCode: -1}
+	}
+	<-done
+	return c.Result
+}
+
+// Done returns a channel that is closed once the command has finished and its Result is complete.
+// It returns nil if the command hasn't been started.
+func (c *Command) Done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.finished
+}
+
+// begin marks the command as running and gives it a fresh Result. Every successful begin must be followed by end.
+func (c *Command) begin() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return ErrAlreadyRunning
 	}
-	c.cmd = exec.Command(c.executable, c.args...)
+	c.running = true
+	c.finished = make(chan struct{})
+	c.cmd = nil
+	c.Result = &Result{}
+	return nil
+}
+
+// end marks the command as finished.
+func (c *Command) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = false
+	close(c.finished)
+}
+
+// prepare builds the underlying exec.Cmd and wires up its input and output, for a run that is stopped with the given
+// grace period. c.release must be called once the process has finished.
+func (c *Command) prepare(gracePeriod time.Duration) error {
+	cmd := exec.Command(c.executable, c.args...)
+	if c.chroot != "" && !strings.Contains(c.executable, "/") {
+		// exec.Command looked the name up on the host
+		cmd.Path, cmd.Err = c.lookPathInChroot()
+	}
+	stopping := make(chan struct{})
+	var stoppingOnce sync.Once
+	c.mu.Lock()
+	c.cmd = cmd
+	c.stopping = stopping
+	c.markStopping = func() { stoppingOnce.Do(func() { close(stopping) }) }
+	c.stopGracePeriod = gracePeriod
+	c.mu.Unlock()
+	if err := c.applySysProcAttr(); err != nil {
+		return err
+	}
+	var stdoutLines, stderrLines []*lineWriter
+	var stdoutLimited, stderrLimited *limitedBuffer
 
 	if c.attachToTerminal {
 		c.cmd.Stdout = os.Stdout
-		// what should we do with stderr?
-		//cmd.Stderr = os.Stderr
+		c.cmd.Stderr = os.Stderr
 		c.cmd.Stdin = os.Stdin
 	} else {
-		c.cmd.Stdout = &c.Result.Stdout
-		c.cmd.Stderr = &c.Result.Stderr
+		var stdout, stderr io.Writer = &c.Result.Stdout, &c.Result.Stderr
+		var err error
+		if c.stdoutLimit != nil {
+			if stdoutLimited, err = newLimitedBuffer(*c.stdoutLimit, "stdout"); err != nil {
+				return err
+			}
+			stdout = stdoutLimited
+		}
+		if c.stderrLimit != nil {
+			if stderrLimited, err = newLimitedBuffer(*c.stderrLimit, "stderr"); err != nil {
+				if stdoutLimited != nil {
+					c.finishLimits(stdoutLimited, nil)
+				}
+				return err
+			}
+			stderr = stderrLimited
+		}
+		c.cmd.Stdout, stdoutLines = outputWriter(stdout, c.stdoutWriters, c.stdoutHandlers)
+		c.cmd.Stderr, stderrLines = outputWriter(stderr, c.stderrWriters, c.stderrHandlers)
+	}
+
+	stdin, closeStdin, err := c.openStdin()
+	if err != nil {
+		c.finishLimits(stdoutLimited, stderrLimited)
+		return err
+	}
+	if stdin != nil {
+		c.cmd.Stdin = stdin
+	}
+	c.release = func() {
+		for _, lw := range append(stdoutLines, stderrLines...) {
+			lw.flush()
+		}
+		closeStdin()
+		c.finishLimits(stdoutLimited, stderrLimited)
 	}
 
 	if c.dir != "" {
 		c.cmd.Dir = c.dir
 	}
+	c.cmd.Env = c.environ()
+	if c.hasResourceControls() {
+		removeCgroup, err := c.prepareResourceControls()
+		if err != nil {
+			c.release()
+			return err
+		}
+		release := c.release
+		c.release = func() {
+			release()
+			if err := removeCgroup(); err != nil && c.Result.Err == nil {
+				c.Result.Err = err
+			}
+		}
+	}
+	return nil
+}
 
-	ticker := time.NewTicker(time.Duration(500) * time.Millisecond)
-	defer ticker.Stop()
-	done := make(chan int, 1)
-	go func() {
-		c.Result.Err = c.cmd.Run()
-		done <- 0
-	}()
-	// wait for the command to finish, or for the kill request to be fulfilled
-	for {
-		select {
-		case <-ticker.C:
-			if c.killRequest != nil && len(c.killRequest) == 1 {
-				break
+// launch prepares and starts the process.
+func (c *Command) launch() error {
+	if err := c.prepare(c.gracePeriod); err != nil {
+		return err
+	}
+	if err := c.start(); err != nil {
+		c.release()
+		return err
+	}
+	c.logStart()
+	return nil
+}
+
+// start starts the prepared process and begins waiting on it in the background.
+func (c *Command) start() error {
+	c.mu.Lock()
+	c.started = time.Now()
+	var pipes *outputPipes
+	var err error
+	if c.pty {
+		var finishPTY func()
+		err = c.startContained(func() (err error) {
+			finishPTY, err = c.startPTY()
+			return err
+		})
+		if finishPTY != nil {
+			release := c.release
+			c.release = func() {
+				finishPTY()
+				release()
 			}
 		}
-		if len(done) == 1 {
-			break
+	} else if pipes, err = c.pipeOutput(); err == nil {
+		err = c.startContained(c.cmd.Start)
+		// the child has its own copies of the write ends now
+		pipes.closeWriters()
+		if err != nil {
+			pipes.closeReaders()
 		}
 	}
+	c.mu.Unlock()
+	if err != nil {
+		return c.missingBinaryError(err)
+	}
+	c.exited = make(chan struct{})
+	c.done = make(chan error, 1)
+	go func() {
+		err := c.cmd.Wait()
+		close(c.exited)
+		if pipes != nil {
+			if outputErr := c.awaitOutput(pipes); outputErr != nil {
+				if err != nil {
+					outputErr = fmt.Errorf("%w, %w", err, outputErr)
+				}
+				err = outputErr
+			}
+		}
+		c.done <- err
+	}()
+	return nil
+}
 
-	if c.treatStderrAsErr && len(c.Result.Stderr.Bytes()) != 0 {
-		if c.Result.Err != nil {
-			c.Result.Err = errors.New(fmt.Sprintf("running the command returned an error: {%s}. Additionally, Stderr is not empty: %s", c.Result.Err.Error(), string(c.Stderr.Bytes())))
-		} else {
-			c.Result.Err = c.stderrAsErr(c.Stderr)
+// outputPipes connect the process to the writers of its output that aren't files.
+type outputPipes struct {
+	readers []*os.File
+	writers []*os.File
+	// copied is closed once everything was copied from the readers, or they were closed
+	copied chan struct{}
+}
+
+/*
+pipeOutput replaces the output writers of the prepared process that aren't files by pipes, copied to the writers by
+goroutines of ours instead of the ones of os/exec: exec.Cmd.Wait waits for its own to drain the pipes, which never
+happens while processes left behind by a stopped command hold them open.
+*/
+func (c *Command) pipeOutput() (*outputPipes, error) {
+	pipes := &outputPipes{copied: make(chan struct{})}
+	var wg sync.WaitGroup
+	for _, output := range []*io.Writer{&c.cmd.Stdout, &c.cmd.Stderr} {
+		if _, isFile := (*output).(*os.File); *output == nil || isFile {
+			continue
+		}
+		r, w, err := os.Pipe()
+		if err != nil {
+			pipes.closeWriters()
+			pipes.closeReaders()
+			return nil, fmt.Errorf("could not create output pipe: %w", err)
 		}
+		pipes.readers = append(pipes.readers, r)
+		pipes.writers = append(pipes.writers, w)
+		wg.Add(1)
+		go func(dst io.Writer) {
+			defer wg.Done()
+			_, _ = io.Copy(dst, r)
+		}(*output)
+		*output = w
 	}
-	return c.Result
+	go func() {
+		wg.Wait()
+		close(pipes.copied)
+	}()
+	return pipes, nil
+}
+
+func (p *outputPipes) closeWriters() {
+	for _, w := range p.writers {
+		w.Close()
+	}
+}
+
+func (p *outputPipes) closeReaders() {
+	for _, r := range p.readers {
+		r.Close()
+	}
+}
+
+/*
+awaitOutput waits for the output of the exited process to be copied. Once the command has been stopped, this is
+bounded by the grace period, since processes it left behind may hold the pipes open for as long as they run.
+*/
+func (c *Command) awaitOutput(pipes *outputPipes) error {
+	defer pipes.closeReaders()
+	select {
+	case <-pipes.copied:
+		return nil
+	case <-c.stopping:
+	}
+	grace := time.NewTimer(c.stopGracePeriod)
+	defer grace.Stop()
+	select {
+	case <-pipes.copied:
+		return nil
+	case <-grace.C:
+	}
+	pipes.closeReaders()
+	<-pipes.copied
+	return ErrOutputAbandoned
+}
+
+// wait waits for the started process to finish, or stops it if ctx is done or the timeout elapses first,
+// and fills in the result.
+func (c *Command) wait(ctx context.Context) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.started.Add(c.timeout))
+		defer cancel()
+	}
+	select {
+	case err := <-c.done:
+		c.Result.Err = err
+	case <-ctx.Done():
+		c.Result.Err = c.stop(ctx)
+	}
+	c.Result.recordExitStatus(c.cmd.ProcessState, c.started)
+	// all output must have been collected before the result is inspected
+	c.release()
+
+	c.classify()
+	c.logFinish()
 }
 
-func (c *Command) stderrAsErr(stderr bytes.Buffer) error {
-	return errors.New(fmt.Sprintf("Stderr from command is not empty: %v", string(stderr.Bytes())))
+// fail records an error that prevented the process from running at all.
+func (c *Command) fail(err error) {
+	c.Result.Err = err
+	c.Result.recordExitStatus(nil, time.Now())
+	c.logFinish()
+}
+
+// stop interrupts the running process, escalating to SIGKILL after the grace period, and waits for it to exit.
+func (c *Command) stop(ctx context.Context) error {
+	stopErr := &StoppedError{Reason: ErrCanceled}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		stopErr.Reason = ErrTimeout
+	}
+	// from now on, output pipes held open by leftover processes are given up on after the grace period
+	c.markStopping()
+	escalate := func() {
+		stopErr.AlivePids = c.alivePids()
+		_ = c.signal(syscall.SIGKILL)
+		stopErr.Killed = true
+		stopErr.Err = <-c.done
+	}
+	if err := c.signal(syscall.SIGINT); err != nil {
+		// the process may have exited in the meantime; in that case Wait is about to return
+		escalate()
+		return stopErr
+	}
+	grace := time.NewTimer(c.stopGracePeriod)
+	defer grace.Stop()
+	select {
+	case <-c.exited:
+		if c.ownsGroup() {
+			// the leader exited, make sure nothing it spawned outlives it
+			_ = c.signal(syscall.SIGKILL)
+		}
+		stopErr.Err = <-c.done
+	case <-grace.C:
+		escalate()
+	}
+	return stopErr
 }
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowWriter takes a while for every line written to it, like a slow consumer of the output.
+type slowWriter struct{}
+
+func (slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Duration(bytes.Count(p, []byte("\n"))) * 20 * time.Microsecond)
+	return len(p), nil
+}
+
+func TestOutputOfExitedCommandIsCollectedInFull(t *testing.T) {
+	lines := 0
+	res := New("seq", "1", "20000").
+		WithGracePeriod(50 * time.Millisecond).
+		WithStdoutWriter(slowWriter{}).
+		WithStdoutLineHandler(func(string) { lines++ }).
+		Run()
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if lines != 20000 || strings.Count(res.Stdout.String(), "\n") != 20000 || res.StdoutTruncated {
+		t.Errorf("collected %d lines and %d bytes of stdout (truncated %v), want all 20000 lines",
+			lines, res.Stdout.Len(), res.StdoutTruncated)
+	}
+}
+
+func TestTimeoutStopsShellWrapper(t *testing.T) {
+	for _, processGroup := range []bool{false, true} {
+		start := time.Now()
+		res := New("bash", "-c", "echo started; sleep 20; echo done").
+			WithProcessGroup(processGroup).
+			WithTimeout(300 * time.Millisecond).
+			WithGracePeriod(300 * time.Millisecond).
+			Run()
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("process group %v: the timeout took %v to stop the command", processGroup, elapsed)
+		}
+		if !errors.Is(res.Err, ErrTimeout) {
+			t.Errorf("process group %v: got error %v, want a timeout", processGroup, res.Err)
+		}
+		if !strings.Contains(res.Stdout.String(), "started") {
+			t.Errorf("process group %v: the output before the timeout was lost: %q", processGroup, res.Stdout.String())
+		}
+	}
+}
+
+func TestAbandonedOutputIsReported(t *testing.T) {
+	// the background sleep survives the shell, which exits on SIGINT, and keeps stdout open
+	res := New("bash", "-c", "sleep 20 & echo started; wait").
+		WithTimeout(200 * time.Millisecond).
+		WithGracePeriod(200 * time.Millisecond).
+		Run()
+	if !errors.Is(res.Err, ErrTimeout) || !errors.Is(res.Err, ErrOutputAbandoned) {
+		t.Errorf("got error %v, want a timeout with abandoned output", res.Err)
+	}
+	if !strings.Contains(res.Stdout.String(), "started") {
+		t.Errorf("the output before the timeout was lost: %q", res.Stdout.String())
+	}
+}
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// WithCredential runs the command as the given user, primary group and supplementary groups.
+// Changing to another user usually requires the current process to be privileged. Only supported on Unix.
+func (c *Command) WithCredential(uid uint32, gid uint32, groups []uint32) *Command {
+	c.uid = &uid
+	c.gid = gid
+	c.groups = append([]uint32(nil), groups...)
+	c.username = ""
+	return c
+}
+
+// WithUser runs the command as the named user, with their primary and supplementary groups.
+// The user is looked up when the command runs, which fails if it doesn't exist. Only supported on Unix.
+func (c *Command) WithUser(name string) *Command {
+	c.uid = nil
+	c.username = name
+	return c
+}
+
+// WithChroot runs the command with dir as its root directory. The executable path and WithDir are resolved inside it,
+// and a bare executable name is looked up in the directories of the command's PATH inside it. Only supported on Unix.
+func (c *Command) WithChroot(dir string) *Command {
+	c.chroot = dir
+	return c
+}
+
+// lookPathInChroot resolves a bare executable name like exec.LookPath does, but in the directories of the command's
+// PATH inside the chroot, and returns its path inside the chroot.
+func (c *Command) lookPathInChroot() (string, error) {
+	pathEnv, ok := c.env["PATH"]
+	if !ok && !c.cleanEnv && !c.unsetEnv["PATH"] {
+		pathEnv = os.Getenv("PATH")
+	}
+	for _, dir := range filepath.SplitList(pathEnv) {
+		// relative directories would depend on the working directory, exec.LookPath refuses them too
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		path := filepath.Join(dir, c.executable)
+		info, err := os.Stat(filepath.Join(c.chroot, path))
+		if err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0 {
+			return path, nil
+		}
+	}
+	return "", &exec.Error{Name: c.executable, Err: exec.ErrNotFound}
+}
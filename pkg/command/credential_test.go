@@ -0,0 +1,49 @@
+package command
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestChrootLooksUpExecutableInside(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "opt", "tools"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "opt", "tools", "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		executable string
+		wantPath   string
+	}{
+		// only exists inside the chroot
+		{"tool", "/opt/tools/tool"},
+		// only exists on the host
+		{"sh", ""},
+		// paths are resolved by the process itself, once in the chroot
+		{"/opt/tools/tool", "/opt/tools/tool"},
+	}
+	for _, test := range tests {
+		c := New(test.executable).WithChroot(root).WithEnvVar("PATH", "/usr/bin:/bin:/opt/tools")
+		if err := c.prepare(c.gracePeriod); err != nil {
+			t.Fatal(err)
+		}
+		c.release()
+		if test.wantPath == "" {
+			if !errors.Is(c.cmd.Err, exec.ErrNotFound) {
+				t.Errorf("%s: got path %q and error %v, want it not to be found", test.executable, c.cmd.Path, c.cmd.Err)
+			}
+			continue
+		}
+		if c.cmd.Err != nil || c.cmd.Path != test.wantPath {
+			t.Errorf("%s: got path %q and error %v, want %q", test.executable, c.cmd.Path, c.cmd.Err, test.wantPath)
+		}
+		if !c.executableExists() {
+			t.Errorf("%s: the executable isn't found to exist", test.executable)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// WithEnv sets the given environment variables for the command, overriding inherited values.
+func (c *Command) WithEnv(env map[string]string) *Command {
+	for key, value := range env {
+		c.WithEnvVar(key, value)
+	}
+	return c
+}
+
+// WithEnvVar sets a single environment variable for the command, overriding an inherited value.
+func (c *Command) WithEnvVar(key string, value string) *Command {
+	if c.env == nil {
+		c.env = map[string]string{}
+	}
+	c.env[key] = value
+	delete(c.unsetEnv, key)
+	return c
+}
+
+// WithoutEnvVar removes an environment variable (inherited or previously set) from the command's environment.
+func (c *Command) WithoutEnvVar(key string) *Command {
+	if c.unsetEnv == nil {
+		c.unsetEnv = map[string]bool{}
+	}
+	c.unsetEnv[key] = true
+	delete(c.env, key)
+	return c
+}
+
+// WithCleanEnv stops the command from inheriting the parent's environment.
+// Only variables set with WithEnv or WithEnvVar are passed to it.
+func (c *Command) WithCleanEnv() *Command {
+	c.cleanEnv = true
+	return c
+}
+
+// environ returns the environment to run the command with, or nil to inherit the parent's environment unchanged.
+func (c *Command) environ() []string {
+	if !c.cleanEnv && len(c.env) == 0 && len(c.unsetEnv) == 0 {
+		return nil
+	}
+	// a non-nil empty slice, since a nil exec.Cmd.Env means "inherit"
+	result := []string{}
+	if !c.cleanEnv {
+		for _, kv := range os.Environ() {
+			key := kv
+			if i := strings.Index(kv, "="); i >= 0 {
+				key = kv[:i]
+			}
+			if _, overridden := c.env[key]; overridden || c.unsetEnv[key] {
+				continue
+			}
+			result = append(result, kv)
+		}
+	}
+	keys := make([]string, 0, len(c.env))
+	for key := range c.env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, key+"="+c.env[key])
+	}
+	return result
+}
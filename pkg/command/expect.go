@@ -0,0 +1,181 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ExpectError is returned by Session.Expect when the pattern didn't appear in the output.
+type ExpectError struct {
+	Pattern string
+	// Reason is ErrTimeout if the pattern didn't appear in time, or io.EOF if the command exited first.
+	Reason error
+	// Output is the output that was received since the last match.
+	Output string
+}
+
+func (e *ExpectError) Error() string {
+	reason := "command exited"
+	if e.Reason == ErrTimeout {
+		reason = "timed out"
+	}
+	return fmt.Sprintf("expecting %q: %s, output since last match: %q", e.Pattern, reason, e.Output)
+}
+
+func (e *ExpectError) Unwrap() error {
+	return e.Reason
+}
+
+/*
+Session drives an interactive command, expect-style: wait for its output to match a pattern, then send it input.
+Stdout and stderr are both matched (with WithPTY they are the same stream anyway).
+*/
+type Session struct {
+	c      *Command
+	stdinR *os.File
+	stdinW *os.File
+
+	mu         sync.Mutex
+	unread     []byte
+	transcript bytes.Buffer
+	// changed is closed (and replaced) whenever output arrives
+	changed chan struct{}
+}
+
+// StartSession starts the command with its stdin and output connected to a new Session.
+// The command's own stdin configuration is replaced.
+func (c *Command) StartSession() (*Session, error) {
+	return c.StartSessionContext(context.Background())
+}
+
+// StartSessionContext is like StartSession, with ctx controlling the command as in StartContext.
+func (c *Command) StartSessionContext(ctx context.Context) (*Session, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("could not create stdin pipe: %w", err)
+	}
+	s := &Session{c: c, stdinR: r, stdinW: w, changed: make(chan struct{})}
+	c.WithStdin(r).WithStdoutWriter(sessionWriter{s}).WithStderrWriter(sessionWriter{s})
+	if err := c.StartContext(ctx); err != nil {
+		r.Close()
+		w.Close()
+		return nil, err
+	}
+	if c.pty {
+		// the terminal reads stdin through our end of the pipe until the command exits
+		go func() {
+			<-c.Done()
+			r.Close()
+		}()
+	} else {
+		// the child has its own copy now, so that writes fail once it exits
+		r.Close()
+	}
+	return s, nil
+}
+
+type sessionWriter struct {
+	s *Session
+}
+
+func (w sessionWriter) Write(p []byte) (int, error) {
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+	w.s.unread = append(w.s.unread, p...)
+	w.s.transcript.Write(p)
+	close(w.s.changed)
+	w.s.changed = make(chan struct{})
+	return len(p), nil
+}
+
+/*
+Expect waits until the output received since the last match matches pattern, consumes the output up to the end of
+the match, and returns the match and its submatches. If the pattern doesn't appear within timeout, the command is
+killed (SIGINT, then SIGKILL after its grace period, like Kill) and an *ExpectError is returned.
+*/
+func (s *Session) Expect(pattern *regexp.Regexp, timeout time.Duration) ([]string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	exited := false
+	for {
+		s.mu.Lock()
+		if loc := pattern.FindSubmatchIndex(s.unread); loc != nil {
+			matches := make([]string, len(loc)/2)
+			for i := range matches {
+				if loc[2*i] >= 0 {
+					matches[i] = string(s.unread[loc[2*i]:loc[2*i+1]])
+				}
+			}
+			s.unread = s.unread[loc[1]:]
+			s.mu.Unlock()
+			return matches, nil
+		}
+		changed := s.changed
+		s.mu.Unlock()
+		if exited {
+			// all output has been delivered by the time the command is done
+			return nil, s.expectError(pattern, io.EOF)
+		}
+
+		select {
+		case <-changed:
+		case <-s.c.Done():
+			exited = true
+		case <-timer.C:
+			_ = s.c.Kill(int(s.c.gracePeriod / time.Millisecond))
+			return nil, s.expectError(pattern, ErrTimeout)
+		}
+	}
+}
+
+// ExpectString is like Expect, for a literal string.
+func (s *Session) ExpectString(text string, timeout time.Duration) error {
+	_, err := s.Expect(regexp.MustCompile(regexp.QuoteMeta(text)), timeout)
+	return err
+}
+
+func (s *Session) expectError(pattern *regexp.Regexp, reason error) *ExpectError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &ExpectError{Pattern: pattern.String(), Reason: reason, Output: string(s.unread)}
+}
+
+// Send writes text to the command's stdin.
+func (s *Session) Send(text string) error {
+	s.mu.Lock()
+	s.transcript.WriteString(text)
+	s.mu.Unlock()
+	if _, err := s.stdinW.WriteString(text); err != nil {
+		return fmt.Errorf("could not send input: %w", err)
+	}
+	return nil
+}
+
+// SendLine writes text followed by a newline to the command's stdin.
+func (s *Session) SendLine(text string) error {
+	return s.Send(text + "\n")
+}
+
+// Close closes the command's stdin, so that it reads EOF.
+func (s *Session) Close() error {
+	return s.stdinW.Close()
+}
+
+// Wait closes the command's stdin and waits for the command to finish.
+func (s *Session) Wait() *Result {
+	_ = s.Close()
+	return s.c.Wait()
+}
+
+// Transcript returns everything the command wrote and everything sent to it so far, in order.
+func (s *Session) Transcript() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.transcript.String()
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EscalationError is returned by Kill when the process (or its process group) was still alive
+// after the timeout and had to be sent SIGKILL.
+type EscalationError struct {
+	// AlivePids are the processes that were still alive when SIGKILL was sent.
+	AlivePids []int
+	// Err is the error from sending SIGKILL, if any.
+	Err error
+	msg string
+}
+
+func (e *EscalationError) Error() string {
+	msg := e.msg
+	if len(e.AlivePids) > 0 {
+		msg += fmt.Sprintf(" (still alive: %v)", e.AlivePids)
+	}
+	if e.Err != nil {
+		msg += ", and then an additional error occured: " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *EscalationError) Unwrap() error {
+	return e.Err
+}
+
+/*
+WithProcessGroup starts the command in its own process group, so that Kill and timeouts signal
+the whole group (e.g. the children of a "bash -c" wrapper) and not only the direct child. Only supported on Unix.
+
+The group members reported in EscalationError.AlivePids and StoppedError.AlivePids are read from /proc
+on Linux and listed with ps(1) on other Unix systems; if ps is not available they are left empty.
+*/
+func (c *Command) WithProcessGroup(processGroup bool) *Command {
+	c.processGroup = processGroup
+	return c
+}
+
+// WithSession starts the command in a new session (setsid), which also makes it the leader of a new process group.
+// Only supported on Unix.
+func (c *Command) WithSession(session bool) *Command {
+	c.session = session
+	return c
+}
+
+func (c *Command) ownsGroup() bool {
+	// a command in a pseudo-terminal is always started in a new session, as the terminal's controlling process
+	return c.processGroup || c.session || c.pty
+}
+
+// alivePids returns the processes that are still alive: the members of the process group if the
+// command owns one, otherwise only the process itself.
+func (c *Command) alivePids() []int {
+	if !c.ownsGroup() {
+		if exists, _ := c.doesProcessExist(); exists {
+			return []int{c.Pid()}
+		}
+		return nil
+	}
+	pids, err := groupPids(c.Pid())
+	if err != nil {
+		return nil
+	}
+	return pids
+}
+
+// parsePsGroup picks the members of pgid out of "pid pgid stat" lines.
+func parsePsGroup(out []byte, pgid int) []int {
+	var pids []int
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || strings.HasPrefix(fields[2], "Z") {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if pgrp, err := strconv.Atoi(fields[1]); err == nil && pgrp == pgid {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package command
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// groupPids lists the processes (excluding zombies) whose process group is pgid using ps(1), as
+// there is no /proc to read outside of Linux.
+func groupPids(pgid int) ([]int, error) {
+	out, err := exec.Command("ps", "-A", "-o", "pid=,pgid=,stat=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list processes: %w", err)
+	}
+	return parsePsGroup(out, pgid), nil
+}
@@ -0,0 +1,78 @@
+//go:build unix
+
+package command
+
+import (
+	"os/exec"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParsePsGroup(t *testing.T) {
+	out := []byte("    1     1 Ss\n" +
+		"  120   100 S+\n" +
+		"  100   100 Ss+\n" +
+		"  130   100 Z+\n" +
+		"  140   140 R\n" +
+		"garbage\n" +
+		"  150   100\n" +
+		"\n" +
+		"  110   100 R+\n")
+	tests := []struct {
+		pgid int
+		want []int
+	}{
+		{100, []int{100, 110, 120}},
+		{140, []int{140}},
+		{1, []int{1}},
+		{999, nil},
+	}
+	for _, tt := range tests {
+		if got := parsePsGroup(out, tt.pgid); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePsGroup(%d) = %v, want %v", tt.pgid, got, tt.want)
+		}
+	}
+}
+
+func TestGroupPidsMatchesPs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("compares the /proc listing with ps")
+	}
+	if _, err := exec.LookPath("ps"); err != nil {
+		t.Skip("ps is not available")
+	}
+	cmd := exec.Command("sh", "-c", "sleep 5 & sleep 5 & wait")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		_ = cmd.Wait()
+	}()
+	pgid := cmd.Process.Pid
+
+	var fromProc []int
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		var err error
+		if fromProc, err = groupPids(pgid); err != nil {
+			t.Fatal(err)
+		}
+		if len(fromProc) == 3 {
+			break
+		}
+	}
+	if len(fromProc) != 3 {
+		t.Fatalf("groupPids(%d) = %v, want the shell and its two children", pgid, fromProc)
+	}
+	out, err := exec.Command("ps", "-A", "-o", "pid=,pgid=,stat=").Output()
+	if err != nil {
+		t.Skipf("ps does not support -o pid=,pgid=,stat=: %v", err)
+	}
+	if fromPs := parsePsGroup(out, pgid); !reflect.DeepEqual(fromPs, fromProc) {
+		t.Errorf("ps lists %v, /proc lists %v", fromPs, fromProc)
+	}
+}
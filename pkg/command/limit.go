@@ -0,0 +1,136 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// OutputLimit caps how much of a command's output is kept in memory in its Result.
+type OutputLimit struct {
+	// HeadBytes is how many bytes from the start of the output are kept.
+	HeadBytes int
+	// TailBytes is how many bytes from the end of the output are kept.
+	TailBytes int
+	// Spill, if true, writes the complete output to a temporary file, whose path is stored in the Result.
+	// The caller is responsible for removing it.
+	Spill bool
+	// SpillDir is the directory the temporary file is created in. Defaults to os.TempDir().
+	SpillDir string
+}
+
+// WithOutputLimit caps both stdout and stderr as described by limit.
+func (c *Command) WithOutputLimit(limit OutputLimit) *Command {
+	return c.WithStdoutLimit(limit).WithStderrLimit(limit)
+}
+
+/*
+WithStdoutLimit caps how much stdout is kept in Result.Stdout: only the first HeadBytes and the last TailBytes are kept,
+separated by a line saying how much was dropped, and Result.StdoutTruncated is set. Writers and line handlers still
+receive the complete output.
+*/
+func (c *Command) WithStdoutLimit(limit OutputLimit) *Command {
+	c.stdoutLimit = &limit
+	return c
+}
+
+// WithStderrLimit caps how much stderr is kept in Result.Stderr, like WithStdoutLimit does for stdout.
+func (c *Command) WithStderrLimit(limit OutputLimit) *Command {
+	c.stderrLimit = &limit
+	return c
+}
+
+// finishLimits stores the output kept by the limited buffers (either may be nil) in the result.
+func (c *Command) finishLimits(stdout *limitedBuffer, stderr *limitedBuffer) {
+	var errs []error
+	if stdout != nil {
+		truncated, path, err := stdout.finish(&c.Result.Stdout)
+		c.Result.StdoutTruncated, c.Result.StdoutFile = truncated, path
+		errs = append(errs, err)
+	}
+	if stderr != nil {
+		truncated, path, err := stderr.finish(&c.Result.Stderr)
+		c.Result.StderrTruncated, c.Result.StderrFile = truncated, path
+		errs = append(errs, err)
+	}
+	for _, err := range errs {
+		if err != nil && c.Result.Err == nil {
+			c.Result.Err = err
+		}
+	}
+}
+
+// limitedBuffer keeps the head and tail of what is written to it, and optionally spills everything to a file.
+// finish must be called once writing is done, to store what was kept in the result buffer.
+type limitedBuffer struct {
+	limit   OutputLimit
+	name    string
+	head    []byte
+	tail    []byte
+	total   int64
+	spill   *os.File
+	spilled error
+}
+
+func newLimitedBuffer(limit OutputLimit, name string) (*limitedBuffer, error) {
+	b := &limitedBuffer{limit: limit, name: name}
+	if limit.Spill {
+		f, err := os.CreateTemp(limit.SpillDir, "shmutils-"+name+"-*.log")
+		if err != nil {
+			return nil, fmt.Errorf("could not create %s spill file: %w", name, err)
+		}
+		b.spill = f
+	}
+	return b, nil
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.spill != nil && b.spilled == nil {
+		_, b.spilled = b.spill.Write(p)
+	}
+	b.total += int64(len(p))
+	rest := p
+	if room := b.limit.HeadBytes - len(b.head); room > 0 {
+		if room > len(rest) {
+			room = len(rest)
+		}
+		b.head = append(b.head, rest[:room]...)
+		rest = rest[room:]
+	}
+	if b.limit.TailBytes > 0 && len(rest) > 0 {
+		b.tail = append(b.tail, rest...)
+		// only compact once the tail has grown to twice its size, to avoid copying on every write
+		if len(b.tail) > 2*b.limit.TailBytes {
+			b.tail = append(b.tail[:0], b.tail[len(b.tail)-b.limit.TailBytes:]...)
+		}
+	}
+	return len(p), nil
+}
+
+// finish writes the kept output to buf, and returns whether anything was dropped and the path of the spill file, if any.
+func (b *limitedBuffer) finish(buf *bytes.Buffer) (truncated bool, spillPath string, err error) {
+	if len(b.tail) > b.limit.TailBytes {
+		b.tail = b.tail[len(b.tail)-b.limit.TailBytes:]
+	}
+	buf.Write(b.head)
+	dropped := b.total - int64(len(b.head)) - int64(len(b.tail))
+	if dropped > 0 {
+		truncated = true
+		if len(b.head) > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(buf, "... [%d bytes of %s truncated] ...\n", dropped, b.name)
+	}
+	buf.Write(b.tail)
+	if b.spill != nil {
+		spillPath = b.spill.Name()
+		err = b.spilled
+		if closeErr := b.spill.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			err = fmt.Errorf("could not write %s spill file: %w", b.name, err)
+		}
+	}
+	return truncated, spillPath, err
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeInChunks(t *testing.T, b *limitedBuffer, s string, chunk int) {
+	t.Helper()
+	for len(s) > 0 {
+		n := chunk
+		if n > len(s) {
+			n = len(s)
+		}
+		if written, err := b.Write([]byte(s[:n])); err != nil || written != n {
+			t.Fatalf("Write wrote %d of %d bytes: %v", written, n, err)
+		}
+		s = s[n:]
+	}
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	output := "0123456789abcdefghijklmnopqrstuvwxyz"
+	tests := []struct {
+		name          string
+		limit         OutputLimit
+		want          string
+		wantTruncated bool
+	}{
+		{"fits", OutputLimit{HeadBytes: 20, TailBytes: 20}, output, false},
+		{"fits exactly", OutputLimit{HeadBytes: 30, TailBytes: 6}, output, false},
+		{"head and tail", OutputLimit{HeadBytes: 4, TailBytes: 3}, "0123\n... [29 bytes of stdout truncated] ...\nxyz", true},
+		{"head only", OutputLimit{HeadBytes: 4}, "0123\n... [32 bytes of stdout truncated] ...\n", true},
+		{"tail only", OutputLimit{TailBytes: 5}, "... [31 bytes of stdout truncated] ...\nvwxyz", true},
+		{"nothing kept", OutputLimit{}, "... [36 bytes of stdout truncated] ...\n", true},
+	}
+	for _, test := range tests {
+		// the result must not depend on how the output is split into writes
+		for _, chunk := range []int{1, 2, 7, len(output)} {
+			b, err := newLimitedBuffer(test.limit, "stdout")
+			if err != nil {
+				t.Fatal(err)
+			}
+			writeInChunks(t, b, output, chunk)
+			var buf bytes.Buffer
+			truncated, path, err := b.finish(&buf)
+			if err != nil || path != "" {
+				t.Errorf("%s: finish returned %q, %v", test.name, path, err)
+			}
+			if buf.String() != test.want || truncated != test.wantTruncated {
+				t.Errorf("%s, writing %d bytes at a time: kept %q (truncated %v), want %q (truncated %v)",
+					test.name, chunk, buf.String(), truncated, test.want, test.wantTruncated)
+			}
+		}
+	}
+}
+
+func TestLimitedBufferSpill(t *testing.T) {
+	dir := t.TempDir()
+	b, err := newLimitedBuffer(OutputLimit{HeadBytes: 2, TailBytes: 2, Spill: true, SpillDir: dir}, "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := strings.Repeat("line of output\n", 1000)
+	writeInChunks(t, b, output, 100)
+	var buf bytes.Buffer
+	truncated, path, err := b.finish(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !truncated || !strings.HasPrefix(path, dir) {
+		t.Errorf("finish returned truncated %v and spill file %q", truncated, path)
+	}
+	spilled, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(spilled) != output {
+		t.Errorf("spill file has %d bytes, want the complete %d bytes of output", len(spilled), len(output))
+	}
+}
@@ -0,0 +1,110 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+Logger receives structured events about command execution: "command started", "command finished" and
+"command retrying", each with alternating key/value attributes such as argv, dir, pid, duration and exit_code.
+A *slog.Logger satisfies this interface.
+*/
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Redactor rewrites the argv of a command before it is logged, e.g. to hide secrets passed as arguments.
+type Redactor func(argv []string) []string
+
+// RedactValues returns a Redactor that replaces every occurrence of any of secrets in the arguments with "****".
+func RedactValues(secrets ...string) Redactor {
+	return func(argv []string) []string {
+		redacted := make([]string, len(argv))
+		for i, arg := range argv {
+			for _, secret := range secrets {
+				if secret != "" {
+					arg = strings.ReplaceAll(arg, secret, "****")
+				}
+			}
+			redacted[i] = arg
+		}
+		return redacted
+	}
+}
+
+/*
+WithLogger sends the command's execution events to logger instead of printing them.
+Without a logger, a verbose command prints a "Running command" line to stderr.
+Nothing is logged either way if verbose is false.
+*/
+func (c *Command) WithLogger(logger Logger) *Command {
+	c.logger = logger
+	return c
+}
+
+// WithRedactor sets a function that rewrites the argv of the command whenever it is logged. Redactors are applied in order.
+func (c *Command) WithRedactor(redactor Redactor) *Command {
+	c.redactors = append(c.redactors, redactor)
+	return c
+}
+
+// loggedArgv returns the executable and arguments of the command, as they should appear in logs.
+func (c *Command) loggedArgv() []string {
+	argv := append([]string{c.executable}, c.args...)
+	for _, redactor := range c.redactors {
+		argv = redactor(argv)
+	}
+	return argv
+}
+
+func (c *Command) logStart() {
+	if !c.verbose {
+		return
+	}
+	argv := c.loggedArgv()
+	if c.logger != nil {
+		c.logger.Info("command started", "argv", argv, "dir", c.dir, "pid", c.Pid())
+		return
+	}
+	if c.dir == "" {
+		fmt.Fprintf(os.Stderr, "Running command: %v\n", Quote(argv...))
+	} else {
+		fmt.Fprintf(os.Stderr, "Running command (cwd=%s) : %v\n", c.dir, Quote(argv...))
+	}
+}
+
+func (c *Command) logFinish() {
+	if !c.verbose || c.logger == nil {
+		return
+	}
+	attrs := []any{
+		"argv", c.loggedArgv(),
+		"dir", c.dir,
+		"pid", c.Pid(),
+		"duration", c.Result.Duration,
+		"exit_code", c.Result.ExitCode,
+	}
+	if c.Result.Signaled {
+		attrs = append(attrs, "signal", c.Result.Signal.String())
+	}
+	if c.Result.Err != nil {
+		c.logger.Error("command finished", append(attrs, "error", c.Result.Err)...)
+		return
+	}
+	c.logger.Info("command finished", attrs...)
+}
+
+func (c *Command) logRetry(attempt int, maxAttempts int, backoff time.Duration, err error) {
+	if !c.verbose {
+		return
+	}
+	if c.logger != nil {
+		c.logger.Info("command retrying", "argv", c.loggedArgv(), "attempt", attempt, "max_attempts", maxAttempts, "backoff", backoff, "error", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Command failed (attempt %d/%d), retrying in %v: %v\n", attempt, maxAttempts, backoff.Round(time.Millisecond), err)
+}
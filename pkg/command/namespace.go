@@ -0,0 +1,11 @@
+package command
+
+/*
+WithNamespaces starts the command in new Linux namespaces, given as a combination of syscall.CLONE_NEW* flags
+(e.g. syscall.CLONE_NEWNS|syscall.CLONE_NEWPID). If the flags include CLONE_NEWUSER, the current user and group are
+mapped to root inside the new user namespace, which allows unprivileged users to create the other namespaces too.
+*/
+func (c *Command) WithNamespaces(cloneflags uintptr) *Command {
+	c.cloneflags = cloneflags
+	return c
+}
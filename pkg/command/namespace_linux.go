@@ -0,0 +1,15 @@
+package command
+
+import (
+	"os"
+	"syscall"
+)
+
+func (c *Command) applyNamespaces(attr *syscall.SysProcAttr) error {
+	attr.Cloneflags = c.cloneflags
+	if c.cloneflags&syscall.CLONE_NEWUSER != 0 {
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	}
+	return nil
+}
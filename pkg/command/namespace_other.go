@@ -0,0 +1,15 @@
+//go:build unix && !linux
+
+package command
+
+import (
+	"errors"
+	"syscall"
+)
+
+func (c *Command) applyNamespaces(attr *syscall.SysProcAttr) error {
+	if c.cloneflags != 0 {
+		return errors.New("namespaces are only supported on linux")
+	}
+	return nil
+}
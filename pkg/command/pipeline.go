@@ -0,0 +1,247 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PipelineResult holds the outcome of running a Pipeline.
+type PipelineResult struct {
+	// Stages holds the result of each command, in pipeline order. Only the last stage's Stdout is captured,
+	// since the output of the other stages is fed to the next stage.
+	Stages []*Result
+	// Err is the error of the last stage, or with pipefail, of the rightmost stage that failed.
+	Err error
+}
+
+/*
+Pipeline runs commands concurrently with the stdout of each command connected to the stdin of the next, like a | b | c.
+If the stages have a Runner other than RealRunner (e.g. a DryRunner set as default), each stage is handed to its
+runner in turn instead, without connecting them. Mixing real and other runners in one pipeline is an error.
+*/
+type Pipeline struct {
+	commands    []*Command
+	pipefail    bool
+	timeout     time.Duration
+	gracePeriod time.Duration
+}
+
+func NewPipeline(commands ...*Command) *Pipeline {
+	return &Pipeline{
+		commands: commands,
+	}
+}
+
+// WithPipefail makes the pipeline fail if any stage fails, not only the last one (like bash's set -o pipefail).
+func (p *Pipeline) WithPipefail(pipefail bool) *Pipeline {
+	p.pipefail = pipefail
+	return p
+}
+
+// WithTimeout stops every stage of the pipeline if it is still running after timeout. A timeout <= 0 means no timeout.
+func (p *Pipeline) WithTimeout(timeout time.Duration) *Pipeline {
+	p.timeout = timeout
+	return p
+}
+
+// WithGracePeriod overrides the grace period between SIGINT and SIGKILL of every stage when the pipeline is stopped.
+func (p *Pipeline) WithGracePeriod(gracePeriod time.Duration) *Pipeline {
+	p.gracePeriod = gracePeriod
+	return p
+}
+
+func (p *Pipeline) String() string {
+	parts := make([]string, len(p.commands))
+	for i, c := range p.commands {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+/*
+Kill sends SIGINT to every stage of the pipeline, and kills the stages that are still alive after timeout
+milliseconds using SIGKILL. The first error encountered is returned.
+*/
+func (p *Pipeline) Kill(timeout int) error {
+	errs := make([]error, len(p.commands))
+	var wg sync.WaitGroup
+	for i, c := range p.commands {
+		wg.Add(1)
+		go func(i int, c *Command) {
+			defer wg.Done()
+			errs[i] = c.Kill(timeout)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pipeline) Run() *PipelineResult {
+	return p.RunContext(context.Background())
+}
+
+/*
+RunContext runs all stages of the pipeline concurrently and waits for all of them to finish. If ctx is done
+(or the pipeline's timeout elapses), every stage still running is stopped like Command.RunContext does.
+*/
+func (p *Pipeline) RunContext(ctx context.Context) *PipelineResult {
+	result := &PipelineResult{}
+	if len(p.commands) == 0 {
+		result.Err = fmt.Errorf("pipeline has no commands")
+		return result
+	}
+	for i, c := range p.commands {
+		if err := c.begin(); err != nil {
+			abandon(p.commands[:i], 0, err)
+			result.Err = err
+			return result
+		}
+	}
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	runsForReal, err := p.realRunners()
+	if err != nil {
+		abandon(p.commands, 0, err)
+		for _, c := range p.commands {
+			result.Stages = append(result.Stages, c.Result)
+		}
+		result.Err = err
+		return result
+	}
+	if !runsForReal {
+		return p.runWithRunners(ctx, result)
+	}
+	for _, c := range p.commands {
+		result.Stages = append(result.Stages, c.Result)
+	}
+
+	for i, c := range p.commands {
+		gracePeriod := c.gracePeriod
+		if p.gracePeriod > 0 {
+			gracePeriod = p.gracePeriod
+		}
+		if err := c.prepare(gracePeriod); err != nil {
+			abandon(p.commands, i, err)
+			result.Err = err
+			return result
+		}
+	}
+
+	// connect each stage's stdout to the next stage's stdin
+	var pipeFiles []*os.File
+	closePipes := func() {
+		for _, f := range pipeFiles {
+			f.Close()
+		}
+	}
+	for i := 0; i < len(p.commands)-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closePipes()
+			result.Err = fmt.Errorf("could not create pipe: %w", err)
+			abandon(p.commands, len(p.commands), result.Err)
+			return result
+		}
+		p.commands[i].cmd.Stdout = w
+		p.commands[i+1].cmd.Stdin = r
+		pipeFiles = append(pipeFiles, r, w)
+	}
+
+	for i, c := range p.commands {
+		if err := c.start(); err != nil {
+			closePipes()
+			stopCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			for _, started := range p.commands[:i] {
+				started.wait(stopCtx)
+				started.end()
+			}
+			abandon(p.commands[i:], len(p.commands)-i, err)
+			result.Err = err
+			return result
+		}
+		c.logStart()
+	}
+	// the children have their own copies of the pipe ends now; ours must be closed so that
+	// each stage sees EOF once the previous one exits
+	closePipes()
+
+	var wg sync.WaitGroup
+	for _, c := range p.commands {
+		wg.Add(1)
+		go func(c *Command) {
+			defer wg.Done()
+			c.wait(ctx)
+			c.end()
+		}(c)
+	}
+	wg.Wait()
+
+	p.setErr(result)
+	return result
+}
+
+// realRunners tells whether the stages run for real, and fails if only some of them do.
+func (p *Pipeline) realRunners() (bool, error) {
+	realStages := 0
+	for _, c := range p.commands {
+		if isRealRunner(c.activeRunner()) {
+			realStages++
+		}
+	}
+	if realStages != 0 && realStages != len(p.commands) {
+		return false, fmt.Errorf("pipeline %s mixes stages that run for real with stages that don't", p)
+	}
+	return realStages != 0, nil
+}
+
+// runWithRunners hands the stages to their runners one after the other, for dry runs and replays.
+func (p *Pipeline) runWithRunners(ctx context.Context, result *PipelineResult) *PipelineResult {
+	for _, c := range p.commands {
+		if res := c.activeRunner().Run(ctx, c); res != nil {
+			c.Result = res
+		}
+		c.end()
+		result.Stages = append(result.Stages, c.Result)
+	}
+	p.setErr(result)
+	return result
+}
+
+// setErr sets the error of the pipeline from the results of its stages.
+func (p *Pipeline) setErr(result *PipelineResult) {
+	result.Err = p.commands[len(p.commands)-1].Result.Err
+	if p.pipefail {
+		for i := len(p.commands) - 1; i >= 0; i-- {
+			if err := p.commands[i].Result.Err; err != nil {
+				result.Err = fmt.Errorf("stage %d (%s) failed: %w", i, p.commands[i].executable, err)
+				break
+			}
+		}
+	}
+}
+
+// abandon marks stages that were never started as failed with err, first releasing the first prepared of them.
+func abandon(stages []*Command, prepared int, err error) {
+	for i, c := range stages {
+		if i < prepared {
+			c.release()
+		}
+		c.fail(err)
+		c.end()
+	}
+}
@@ -0,0 +1,26 @@
+package command
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPipelineGracePeriodOverridesStages(t *testing.T) {
+	// the first stage ignores SIGINT, so it is only stopped by SIGKILL once the grace period is over
+	stubborn := New("sh", "-c", "trap '' INT; sleep 5").WithGracePeriod(time.Minute)
+	start := time.Now()
+	res := NewPipeline(stubborn, New("cat")).
+		WithTimeout(200 * time.Millisecond).
+		WithGracePeriod(200 * time.Millisecond).
+		Run()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("the pipeline took %v to stop, its grace period wasn't applied", elapsed)
+	}
+	if !errors.Is(res.Stages[0].Err, ErrTimeout) {
+		t.Errorf("the first stage failed with %v, want a timeout", res.Stages[0].Err)
+	}
+	if stubborn.gracePeriod != time.Minute {
+		t.Errorf("the pipeline changed the grace period of its stage to %v", stubborn.gracePeriod)
+	}
+}
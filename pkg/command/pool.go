@@ -0,0 +1,107 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSkipped is the error of a pool command that was never started, because the pool was canceled or failed fast.
+var ErrSkipped = errors.New("command skipped")
+
+// PoolResult holds the outcome of running a Pool.
+type PoolResult struct {
+	// Results holds the result of each command, in the order they were added to the pool.
+	Results []*Result
+	// Err is the error of the first command that failed: in the order they were added when collecting all results,
+	// or the first to finish with an error when failing fast.
+	Err error
+}
+
+// Pool runs many commands concurrently, with at most a given number of them running at once.
+type Pool struct {
+	concurrency int
+	commands    []*Command
+	failFast    bool
+}
+
+// NewPool returns a pool that runs commands with at most concurrency of them running at once. Values < 1 mean 1.
+func NewPool(concurrency int, commands ...*Command) *Pool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Pool{
+		concurrency: concurrency,
+		commands:    commands,
+	}
+}
+
+// Add adds commands to the pool. They are run after the ones added before them, by the next Run.
+func (p *Pool) Add(commands ...*Command) *Pool {
+	p.commands = append(p.commands, commands...)
+	return p
+}
+
+// WithFailFast makes the pool stop as soon as a command fails: the running commands are canceled and the ones that
+// haven't started yet are skipped. By default, all commands run and all results are collected.
+func (p *Pool) WithFailFast(failFast bool) *Pool {
+	p.failFast = failFast
+	return p
+}
+
+func (p *Pool) Run() *PoolResult {
+	return p.RunContext(context.Background())
+}
+
+/*
+RunContext runs all commands of the pool and waits for them to finish. ctx is shared by all of them: once it is done,
+the running commands are stopped as described in Command.RunContext, and the rest are skipped with ErrSkipped.
+*/
+func (p *Pool) RunContext(ctx context.Context) *PoolResult {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := &PoolResult{Results: make([]*Result, len(p.commands))}
+	var firstErrMu sync.Mutex
+	var firstErr error
+	slots := make(chan struct{}, p.concurrency)
+	var wg sync.WaitGroup
+	for i, c := range p.commands {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			result.Results[i] = &Result{Err: ErrSkipped, ExitCode: -1}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, c *Command) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			res := c.RunContext(ctx)
+			result.Results[i] = res
+			if res.Err != nil && p.failFast {
+				firstErrMu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("command %d (%s) failed: %w", i, c.executable, res.Err)
+					cancel()
+				}
+				firstErrMu.Unlock()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	result.Err = firstErr
+	if result.Err == nil {
+		for i, res := range result.Results {
+			if res.Err != nil {
+				result.Err = fmt.Errorf("command %d (%s) failed: %w", i, p.commands[i].executable, res.Err)
+				break
+			}
+		}
+	}
+	return result
+}
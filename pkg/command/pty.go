@@ -0,0 +1,23 @@
+package command
+
+import "io"
+
+/*
+WithPTY runs the command in a new pseudo-terminal, so that it behaves as if it was started from an interactive terminal.
+Stdout and stderr are both written to the terminal, so everything ends up in Result.Stdout (and the stdout writers and
+line handlers), and Result.Stderr stays empty.
+
+Combined with WithAttachToTerminal, the terminal is wired to the local one instead: the local terminal is put into raw
+mode for the duration of the command (and restored afterwards), and window size changes are forwarded to the command.
+Only supported on Unix.
+*/
+func (c *Command) WithPTY(pty bool) *Command {
+	c.pty = pty
+	return c
+}
+
+// WithPTYTranscript records everything the command writes to its pseudo-terminal to w. It only has an effect with WithPTY.
+func (c *Command) WithPTYTranscript(w io.Writer) *Command {
+	c.ptyTranscript = w
+	return c
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package command
+
+import "errors"
+
+func (c *Command) startPTY() (func(), error) {
+	return nil, errors.New("pseudo-terminals are only supported on unix")
+}
@@ -0,0 +1,157 @@
+//go:build unix
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// ptyDrainTimeout is how long to keep reading leftover output from the pseudo-terminal after the process exited.
+const ptyDrainTimeout = 500 * time.Millisecond
+
+// startPTY starts the prepared process attached to a new pseudo-terminal, and starts copying between it and the
+// command's input and output. It returns a function that waits for the output to be drained and restores the local
+// terminal, to be called once the process has exited.
+func (c *Command) startPTY() (func(), error) {
+	// the output and input chosen by prepare, which the terminal is connected to instead of the process
+	output := c.cmd.Stdout
+	input := c.cmd.Stdin
+	c.cmd.Stdout, c.cmd.Stderr, c.cmd.Stdin = nil, nil, nil
+
+	var size *pty.Winsize
+	if c.attachToTerminal {
+		size, _ = pty.GetsizeFull(os.Stdin)
+	}
+	ptmx, err := pty.StartWithSize(c.cmd, size)
+	if err != nil {
+		return nil, fmt.Errorf("could not start command in a pseudo-terminal: %w", err)
+	}
+	if c.ptyTranscript != nil {
+		output = io.MultiWriter(output, c.ptyTranscript)
+	}
+
+	restoreTerminal := func() {}
+	stopResizing := func() {}
+	if c.attachToTerminal && term.IsTerminal(int(os.Stdin.Fd())) {
+		if state, err := term.MakeRaw(int(os.Stdin.Fd())); err == nil {
+			restoreTerminal = func() { _ = term.Restore(int(os.Stdin.Fd()), state) }
+		}
+		stopResizing = forwardWindowSize(ptmx)
+	}
+
+	stopInput := func() {}
+	if input != nil {
+		stopInput = pumpInput(ptmx, input)
+	}
+	copied := make(chan struct{})
+	go func() {
+		// reading returns EIO once the process (and anything else holding the terminal) exits
+		_, _ = io.Copy(output, ptmx)
+		close(copied)
+	}()
+
+	return func() {
+		select {
+		case <-copied:
+		case <-time.After(ptyDrainTimeout):
+		}
+		stopInput()
+		ptmx.Close()
+		<-copied
+		stopResizing()
+		restoreTerminal()
+	}, nil
+}
+
+/*
+pumpInput copies input to the pseudo-terminal until the returned function is called. When input is a file (like the
+local stdin), it is only read once it has data, so that stopping doesn't leave a read pending that would swallow the
+next keystrokes meant for whatever reads the local stdin after the command (e.g. the next command attached to it).
+*/
+func pumpInput(ptmx *os.File, input io.Reader) func() {
+	f, ok := input.(*os.File)
+	var stopR, stopW *os.File
+	var err error
+	if ok {
+		stopR, stopW, err = os.Pipe()
+	}
+	if !ok || err != nil {
+		go func() {
+			_, _ = io.Copy(ptmx, input)
+		}()
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 32*1024)
+		fds := []unix.PollFd{
+			{Fd: int32(f.Fd()), Events: unix.POLLIN},
+			{Fd: int32(stopR.Fd()), Events: unix.POLLIN},
+		}
+		for {
+			if _, err := unix.Poll(fds, -1); err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				return
+			}
+			if fds[1].Revents != 0 {
+				return
+			}
+			if fds[0].Revents == 0 {
+				continue
+			}
+			n, err := unix.Read(int(fds[0].Fd), buf)
+			if n > 0 {
+				if _, err := ptmx.Write(buf[:n]); err != nil {
+					return
+				}
+			}
+			if err == unix.EINTR || err == unix.EAGAIN {
+				continue
+			}
+			if err != nil || n == 0 {
+				// EOF or a broken input
+				return
+			}
+		}
+	}()
+	return func() {
+		stopW.Close()
+		// a write to a terminal that nobody reads anymore may block until the terminal is closed, after this
+		select {
+		case <-done:
+		case <-time.After(ptyDrainTimeout):
+		}
+		go func() {
+			<-done
+			stopR.Close()
+		}()
+	}
+}
+
+// forwardWindowSize resizes the pseudo-terminal to the size of the local one whenever it changes (SIGWINCH).
+func forwardWindowSize(ptmx *os.File) func() {
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	go func() {
+		for range resized {
+			_ = pty.InheritSize(os.Stdin, ptmx)
+		}
+	}()
+	return func() {
+		signal.Stop(resized)
+		close(resized)
+	}
+}
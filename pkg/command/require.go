@@ -0,0 +1,200 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Dependency describes an external executable that commands rely on.
+type Dependency struct {
+	// Name is the name of the executable, as looked up in $PATH.
+	Name string
+	// VersionArgs are the arguments that make the executable print its version, e.g. []string{"--version"}.
+	// If empty, the version isn't probed.
+	VersionArgs []string
+	// VersionPattern extracts the version from the probe's output: its first submatch if it has one, or else the
+	// whole match. If nil, the first line of output is used.
+	VersionPattern *regexp.Regexp
+	// InstallHint tells the user how to install the executable if it is missing.
+	InstallHint string
+}
+
+// Binary is a resolved executable.
+type Binary struct {
+	Name string
+	Path string
+
+	dependency  Dependency
+	versionOnce sync.Once
+	version     string
+	versionErr  error
+}
+
+// MissingBinaryError is returned when an executable can't be found.
+type MissingBinaryError struct {
+	Name        string
+	InstallHint string
+	Err         error
+}
+
+func (e *MissingBinaryError) Error() string {
+	msg := fmt.Sprintf("%q was not found in $PATH", e.Name)
+	if e.InstallHint != "" {
+		msg += " (" + e.InstallHint + ")"
+	}
+	return msg
+}
+
+func (e *MissingBinaryError) Unwrap() error {
+	return e.Err
+}
+
+// MissingBinariesError is returned by Require, listing every executable that couldn't be found.
+type MissingBinariesError struct {
+	Missing []*MissingBinaryError
+}
+
+func (e *MissingBinariesError) Error() string {
+	messages := make([]string, len(e.Missing))
+	for i, missing := range e.Missing {
+		messages[i] = missing.Error()
+	}
+	return "missing required executables: " + strings.Join(messages, "; ")
+}
+
+var (
+	binariesMu   sync.Mutex
+	dependencies = map[string]Dependency{}
+	// binaries caches resolved executables by $PATH and name
+	binaries = map[string]*Binary{}
+)
+
+// RegisterDependency declares how to probe the version of an executable and how to install it,
+// for use by Which, Require, Check and the errors of commands whose executable is missing.
+func RegisterDependency(dependency Dependency) {
+	binariesMu.Lock()
+	defer binariesMu.Unlock()
+	dependencies[dependency.Name] = dependency
+	for key, binary := range binaries {
+		if binary.Name == dependency.Name {
+			delete(binaries, key)
+		}
+	}
+}
+
+// Which resolves an executable in $PATH. Results are cached until $PATH changes.
+func Which(name string) (*Binary, error) {
+	binariesMu.Lock()
+	defer binariesMu.Unlock()
+	key := os.Getenv("PATH") + "\x00" + name
+	if binary, ok := binaries[key]; ok {
+		return binary, nil
+	}
+	dependency, ok := dependencies[name]
+	if !ok {
+		dependency = Dependency{Name: name}
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, &MissingBinaryError{Name: name, InstallHint: dependency.InstallHint, Err: err}
+	}
+	binary := &Binary{Name: name, Path: path, dependency: dependency}
+	binaries[key] = binary
+	return binary, nil
+}
+
+// Version runs the executable's registered version probe once and returns the version it reports.
+func (b *Binary) Version() (string, error) {
+	b.versionOnce.Do(func() {
+		if len(b.dependency.VersionArgs) == 0 {
+			b.versionErr = fmt.Errorf("no version probe registered for %q", b.Name)
+			return
+		}
+		res := New(b.Path, b.dependency.VersionArgs...).WithVerbose(false).Run()
+		if res.Err != nil {
+			b.versionErr = fmt.Errorf("could not probe version of %q: %w", b.Name, res.Err)
+			return
+		}
+		// some tools print their version to stderr
+		output := res.Stdout.String() + res.Stderr.String()
+		if pattern := b.dependency.VersionPattern; pattern != nil {
+			match := pattern.FindStringSubmatch(output)
+			switch {
+			case match == nil:
+				b.versionErr = fmt.Errorf("version of %q not found in %q", b.Name, output)
+			case len(match) > 1:
+				b.version = match[1]
+			default:
+				b.version = match[0]
+			}
+			return
+		}
+		b.version = strings.TrimSpace(strings.SplitN(strings.TrimSpace(output), "\n", 2)[0])
+	})
+	return b.version, b.versionErr
+}
+
+// Require checks that all the given executables can be found, and returns a *MissingBinariesError listing the ones
+// that can't.
+func Require(names ...string) error {
+	var missing []*MissingBinaryError
+	for _, name := range names {
+		if _, err := Which(name); err != nil {
+			var missingErr *MissingBinaryError
+			if !errors.As(err, &missingErr) {
+				return err
+			}
+			missing = append(missing, missingErr)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingBinariesError{Missing: missing}
+	}
+	return nil
+}
+
+// Check verifies that the command's executable can be found before running it, returning a *MissingBinaryError if not.
+func (c *Command) Check() error {
+	_, err := Which(c.executable)
+	return err
+}
+
+// missingBinaryError turns the error of starting a process whose executable doesn't exist into a *MissingBinaryError.
+func (c *Command) missingBinaryError(err error) error {
+	var pathErr *os.PathError
+	notFound := errors.Is(err, exec.ErrNotFound) ||
+		errors.As(err, &pathErr) && pathErr.Op == "fork/exec" && errors.Is(pathErr.Err, os.ErrNotExist) && !c.executableExists()
+	if !notFound {
+		return err
+	}
+	binariesMu.Lock()
+	hint := dependencies[c.executable].InstallHint
+	binariesMu.Unlock()
+	return &MissingBinaryError{Name: c.executable, InstallHint: hint, Err: err}
+}
+
+// executableExists tells apart a missing executable from other reasons for a process to fail to start with ENOENT,
+// such as a missing working directory.
+func (c *Command) executableExists() bool {
+	if !strings.Contains(c.executable, "/") {
+		var err error
+		if c.chroot != "" {
+			_, err = c.lookPathInChroot()
+		} else {
+			_, err = exec.LookPath(c.executable)
+		}
+		return err == nil
+	}
+	path := c.executable
+	if !filepath.IsAbs(path) && c.dir != "" {
+		path = filepath.Join(c.dir, path)
+	}
+	_, err := os.Stat(filepath.Join(c.chroot, path))
+	return err == nil
+}
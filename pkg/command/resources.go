@@ -0,0 +1,76 @@
+package command
+
+// ResourceLimits are rlimits applied to the command's process. Zero fields are left unchanged.
+type ResourceLimits struct {
+	// CPUSeconds is the maximum CPU time in seconds (RLIMIT_CPU).
+	CPUSeconds uint64
+	// AddressSpace is the maximum size of the virtual memory in bytes (RLIMIT_AS).
+	AddressSpace uint64
+	// OpenFiles is the maximum number of open file descriptors (RLIMIT_NOFILE).
+	OpenFiles uint64
+}
+
+// IOPriorityClass is the I/O scheduling class used by WithIOPriority, as in ionice(1).
+type IOPriorityClass int
+
+const (
+	IOPriorityRealtime   IOPriorityClass = 1
+	IOPriorityBestEffort IOPriorityClass = 2
+	IOPriorityIdle       IOPriorityClass = 3
+)
+
+// CgroupLimits describes the cgroup v2 a command is placed in by WithCgroup.
+type CgroupLimits struct {
+	// Parent is the cgroup directory under which a cgroup is created for the command. Defaults to /sys/fs/cgroup.
+	// It must be writable by the current user and have the memory and cpu controllers enabled for its children.
+	Parent string
+	// MemoryMax is the memory limit in bytes (memory.max). 0 means no limit.
+	MemoryMax int64
+	// CPUMax is the number of CPUs the command may use, e.g. 0.5 for half a CPU (cpu.max). 0 means no limit.
+	CPUMax float64
+}
+
+/*
+WithRlimits sets resource limits for the command's process.
+Resource limits, priorities and cgroups are only supported on Linux, and are in place before the command's first
+instruction: the process is started traced, so that it stops right after exec, and its rlimits and priorities are set
+while it is stopped. Because of the tracing, a set-user-ID or set-group-ID executable runs without its privileges,
+and the command fails to start where ptrace is not allowed (e.g. with kernel.yama.ptrace_scope=3). If a limit or
+priority can't be applied, the process is killed before it runs and the command fails.
+*/
+func (c *Command) WithRlimits(limits ResourceLimits) *Command {
+	c.rlimits = &limits
+	return c
+}
+
+// WithNice sets the scheduling priority (nice value, -20 to 19) of the command's process. See WithRlimits.
+func (c *Command) WithNice(nice int) *Command {
+	c.nice = &nice
+	return c
+}
+
+// WithIOPriority sets the I/O scheduling class and level (0 to 7, lower is higher priority) of the command's process.
+// See WithRlimits.
+func (c *Command) WithIOPriority(class IOPriorityClass, level int) *Command {
+	c.ioPriority = &ioPriority{class: class, level: level}
+	return c
+}
+
+/*
+WithCgroup starts the command's process in a new cgroup v2 with the given limits (this needs Linux 5.7). The cgroup
+is removed once the command has finished, after killing the processes it left behind in it. If it can't be removed,
+the command fails with that error. See WithRlimits.
+*/
+func (c *Command) WithCgroup(limits CgroupLimits) *Command {
+	c.cgroup = &limits
+	return c
+}
+
+type ioPriority struct {
+	class IOPriorityClass
+	level int
+}
+
+func (c *Command) hasResourceControls() bool {
+	return c.rlimits != nil || c.nice != nil || c.ioPriority != nil || c.cgroup != nil
+}
@@ -0,0 +1,206 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const defaultCgroupParent = "/sys/fs/cgroup"
+
+// cgroupRemoveTimeout is how long to wait for the processes left in a command's cgroup to be gone once killed.
+const cgroupRemoveTimeout = time.Second
+
+// cgroupCount makes the names of the cgroups created by the current process unique.
+var cgroupCount atomic.Int64
+
+/*
+prepareResourceControls arranges for the prepared process to be contained from its very first instruction, so that
+nothing it forks early escapes its limits:
+  - it is created directly inside its cgroup (clone3 with CLONE_INTO_CGROUP, which needs Linux 5.7),
+  - if it has rlimits or priorities, it is traced (PTRACE_TRACEME) so that it stops right after exec, before running
+    any instruction of the command, and startContained applies them while it is stopped.
+
+It returns a function that removes the cgroup, to be called once the process has exited.
+*/
+func (c *Command) prepareResourceControls() (func() error, error) {
+	c.cmd.SysProcAttr.Ptrace = c.hasProcessControls()
+	if c.cgroup == nil {
+		return func() error { return nil }, nil
+	}
+	dir, fd, err := createCgroup(*c.cgroup)
+	if err != nil {
+		return nil, err
+	}
+	c.cmd.SysProcAttr.UseCgroupFD = true
+	c.cmd.SysProcAttr.CgroupFD = fd
+	return func() error {
+		_ = unix.Close(fd)
+		return removeCgroup(dir)
+	}, nil
+}
+
+// hasProcessControls tells whether the command has rlimits or priorities, which are set on its process once started.
+func (c *Command) hasProcessControls() bool {
+	return c.rlimits != nil || c.nice != nil || c.ioPriority != nil
+}
+
+/*
+startContained starts the prepared process with start, then applies its rlimits and priorities while it is stopped
+after exec and lets it run. If they can't be applied, the process is killed before it runs at all.
+*/
+func (c *Command) startContained(start func() error) error {
+	if !c.hasProcessControls() {
+		return start()
+	}
+	// the tracer is the thread that started the process, only it may detach from it
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := start(); err != nil {
+		return err
+	}
+	pid := c.cmd.Process.Pid
+	err := awaitExecStop(pid)
+	if err == nil {
+		err = c.applyProcessControls(pid)
+	}
+	if err == nil {
+		if err = unix.PtraceDetach(pid); err != nil {
+			err = fmt.Errorf("could not resume process: %w", err)
+		}
+	}
+	if err != nil {
+		_ = c.cmd.Process.Kill()
+		_ = c.cmd.Wait()
+		return err
+	}
+	return nil
+}
+
+// awaitExecStop waits for the traced process to stop with SIGTRAP, which it gets once exec has succeeded.
+func awaitExecStop(pid int) error {
+	for {
+		var status unix.WaitStatus
+		_, err := unix.Wait4(pid, &status, unix.WALL, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("could not wait for process to start: %w", err)
+		}
+		if !status.Stopped() || status.StopSignal() != unix.SIGTRAP {
+			return fmt.Errorf("process didn't stop after exec (wait status %#x)", uint32(status))
+		}
+		return nil
+	}
+}
+
+func (c *Command) applyProcessControls(pid int) error {
+	if c.rlimits != nil {
+		limits := []struct {
+			resource int
+			value    uint64
+			name     string
+		}{
+			{unix.RLIMIT_CPU, c.rlimits.CPUSeconds, "cpu"},
+			{unix.RLIMIT_AS, c.rlimits.AddressSpace, "address space"},
+			{unix.RLIMIT_NOFILE, c.rlimits.OpenFiles, "open files"},
+		}
+		for _, limit := range limits {
+			if limit.value == 0 {
+				continue
+			}
+			rlimit := &unix.Rlimit{Cur: limit.value, Max: limit.value}
+			if err := unix.Prlimit(pid, limit.resource, rlimit, nil); err != nil {
+				return fmt.Errorf("could not set %s limit: %w", limit.name, err)
+			}
+		}
+	}
+	// the process has a single thread at this point, so setting these on it covers all of the threads it will create
+	if c.nice != nil {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, *c.nice); err != nil {
+			return fmt.Errorf("could not set nice value: %w", err)
+		}
+	}
+	if c.ioPriority != nil {
+		const ioprioWhoProcess = 1
+		const ioprioClassShift = 13
+		prio := int(c.ioPriority.class)<<ioprioClassShift | c.ioPriority.level
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+			return fmt.Errorf("could not set io priority: %w", errno)
+		}
+	}
+	return nil
+}
+
+// createCgroup creates a cgroup for a command and sets its limits. It returns its directory, and a descriptor of it
+// to start the process in.
+func createCgroup(limits CgroupLimits) (string, int, error) {
+	parent := limits.Parent
+	if parent == "" {
+		parent = defaultCgroupParent
+	}
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return "", -1, fmt.Errorf("%s is not a cgroup v2 directory: %w", parent, err)
+	}
+	name := fmt.Sprintf("shmutils-%d-%d", os.Getpid(), cgroupCount.Add(1))
+	dir := filepath.Join(parent, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", -1, fmt.Errorf("could not create cgroup: %w", err)
+	}
+	fail := func(err error) (string, int, error) {
+		_ = os.Remove(dir)
+		return "", -1, err
+	}
+	write := func(file string, value string) error {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+			return fmt.Errorf("could not write %s of cgroup: %w", file, err)
+		}
+		return nil
+	}
+	if limits.MemoryMax > 0 {
+		if err := write("memory.max", strconv.FormatInt(limits.MemoryMax, 10)); err != nil {
+			return fail(err)
+		}
+	}
+	if limits.CPUMax > 0 {
+		const period = 100000
+		quota := int64(limits.CPUMax * period)
+		if err := write("cpu.max", fmt.Sprintf("%d %d", quota, period)); err != nil {
+			return fail(err)
+		}
+	}
+	fd, err := unix.Open(dir, unix.O_DIRECTORY|unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fail(fmt.Errorf("could not open cgroup: %w", err))
+	}
+	return dir, fd, nil
+}
+
+// removeCgroup removes the cgroup of a command that has exited. Processes the command left behind in it would keep
+// it from being removed, so they are killed first.
+func removeCgroup(dir string) error {
+	err := os.Remove(dir)
+	if errors.Is(err, unix.EBUSY) {
+		// cgroup.kill needs Linux 5.14
+		if killErr := os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0644); killErr != nil {
+			return fmt.Errorf("could not remove cgroup %s, which still has processes: %w", dir, killErr)
+		}
+		// killed processes leave the cgroup asynchronously
+		deadline := time.Now().Add(cgroupRemoveTimeout)
+		for err = os.Remove(dir); errors.Is(err, unix.EBUSY) && time.Now().Before(deadline); err = os.Remove(dir) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not remove cgroup: %w", err)
+	}
+	return nil
+}
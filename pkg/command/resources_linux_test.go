@@ -0,0 +1,32 @@
+package command
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRlimitsAndNiceAreSetBeforeTheCommandRuns(t *testing.T) {
+	for _, pty := range []bool{false, true} {
+		res := New("sh", "-c", "ulimit -n; nice").
+			WithRlimits(ResourceLimits{OpenFiles: 77}).
+			WithNice(7).
+			WithPTY(pty).
+			Run()
+		if res.Err != nil {
+			t.Fatalf("pty %v: %v", pty, res.Err)
+		}
+		if got := strings.Fields(res.Stdout.String()); len(got) != 2 || got[0] != "77" || got[1] != "7" {
+			t.Errorf("pty %v: got open files limit and nice value %q, want 77 and 7", pty, got)
+		}
+	}
+}
+
+func TestUnappliableRlimitFailsTheCommand(t *testing.T) {
+	res := New("sh", "-c", "echo ran").WithRlimits(ResourceLimits{OpenFiles: 1 << 40}).Run()
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "could not set open files limit") {
+		t.Errorf("got error %v, want the limit to be reported", res.Err)
+	}
+	if res.Stdout.Len() > 0 {
+		t.Errorf("the command ran: %q", res.Stdout.String())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package command
+
+import "errors"
+
+func (c *Command) prepareResourceControls() (func() error, error) {
+	return nil, errors.New("resource limits, priorities and cgroups are only supported on linux")
+}
+
+func (c *Command) startContained(start func() error) error {
+	return start()
+}
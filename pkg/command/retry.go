@@ -0,0 +1,116 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// RetryPolicy configures how a command is retried by WithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times the command is run, including the first. Values < 1 mean 1.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Each following delay is multiplied by Multiplier.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. 0 means no cap.
+	MaxBackoff time.Duration
+	// Multiplier is the factor the backoff grows by after each attempt. Values < 1 mean 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction of it in either direction, e.g. 0.2 for +-20%.
+	Jitter float64
+	// ShouldRetry decides whether a finished attempt should be retried. If nil, any attempt with a non-nil
+	// Result.Err is retried (which includes stderr output when WithTreatStderrAsErr is set).
+	ShouldRetry func(res *Result) bool
+}
+
+// RetryOnExitCodes returns a ShouldRetry predicate that retries attempts which exited with one of codes.
+func RetryOnExitCodes(codes ...int) func(res *Result) bool {
+	return func(res *Result) bool {
+		for _, code := range codes {
+			if res.ExitCode == code {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryOnStderr returns a ShouldRetry predicate that retries failed attempts whose stderr matches pattern.
+func RetryOnStderr(pattern *regexp.Regexp) func(res *Result) bool {
+	return func(res *Result) bool {
+		return res.Err != nil && pattern.Match(res.Stderr.Bytes())
+	}
+}
+
+/*
+WithRetry makes Run and RunContext retry the command according to policy. The returned Result is the one of the last
+attempt, with the results of the earlier attempts in Result.Attempts. Start only ever runs a single attempt.
+
+A reader set with WithStdin is drained by the first attempt, so commands with one can't be retried and fail right
+away; use WithStdinString or WithStdinFile instead, which are reopened for each attempt.
+*/
+func (c *Command) WithRetry(policy RetryPolicy) *Command {
+	c.retry = &policy
+	return c
+}
+
+func (c *Command) runWithRetry(ctx context.Context) *Result {
+	policy := c.retry
+	shouldRetry := policy.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = func(res *Result) bool { return res.Err != nil }
+	}
+	if c.stdin != nil && policy.MaxAttempts > 1 {
+		return &Result{Err: errors.New("a command with a stdin reader can't be retried, use WithStdinString or WithStdinFile"), ExitCode: -1}
+	}
+	var attempts []*Result
+	for attempt := 1; ; attempt++ {
+		if err := c.StartContext(ctx); errors.Is(err, ErrAlreadyRunning) {
+			return &Result{Err: err, ExitCode: -1, Attempts: attempts}
+		}
+		res := c.Wait()
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !shouldRetry(res) {
+			res.Attempts = attempts
+			return res
+		}
+		attempts = append(attempts, res)
+
+		backoff := policy.backoff(attempt)
+		c.logRetry(attempt, policy.MaxAttempts, backoff, res.Err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			res.Attempts = attempts[:len(attempts)-1]
+			return res
+		}
+	}
+}
+
+// backoff returns the delay to wait after the given (1-based) failed attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if backoff < 0 {
+		return 0
+	}
+	return time.Duration(backoff)
+}
@@ -0,0 +1,231 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+)
+
+/*
+Runner executes commands on behalf of Run, RunContext and Start. Besides RealRunner, which runs processes, there
+are runners for dry runs (DryRunner) and for recording and replaying command output in tests (Recorder, Replayer).
+
+Run is called with a command that has already been marked as running, and returns its result.
+*/
+type Runner interface {
+	Run(ctx context.Context, c *Command) *Result
+}
+
+var (
+	defaultRunnerMu sync.RWMutex
+	defaultRunner   Runner = RealRunner{}
+)
+
+// SetDefaultRunner sets the runner used by commands that don't have one set with WithRunner, and returns the previous one.
+// A nil runner restores RealRunner.
+func SetDefaultRunner(runner Runner) Runner {
+	if runner == nil {
+		runner = RealRunner{}
+	}
+	defaultRunnerMu.Lock()
+	defer defaultRunnerMu.Unlock()
+	previous := defaultRunner
+	defaultRunner = runner
+	return previous
+}
+
+// WithRunner sets the runner that executes this command, overriding the default runner.
+func (c *Command) WithRunner(runner Runner) *Command {
+	c.runner = runner
+	return c
+}
+
+func (c *Command) activeRunner() Runner {
+	if c.runner != nil {
+		return c.runner
+	}
+	defaultRunnerMu.RLock()
+	defer defaultRunnerMu.RUnlock()
+	return defaultRunner
+}
+
+// Argv returns the executable and arguments of the command.
+func (c *Command) Argv() []string {
+	return append([]string{c.executable}, c.args...)
+}
+
+// Dir returns the working directory of the command, or "" for the current one.
+func (c *Command) Dir() string {
+	return c.dir
+}
+
+// RealRunner runs commands as processes. It is the default runner.
+type RealRunner struct{}
+
+func (RealRunner) Run(ctx context.Context, c *Command) *Result {
+	if err := c.launch(); err != nil {
+		c.fail(err)
+	} else {
+		c.wait(ctx)
+	}
+	return c.Result
+}
+
+func isRealRunner(runner Runner) bool {
+	switch runner.(type) {
+	case RealRunner, *RealRunner:
+		return true
+	}
+	return false
+}
+
+// DryRunner doesn't run commands, it only prints the argv (after redaction) of each of them and reports success.
+type DryRunner struct {
+	out io.Writer
+}
+
+// NewDryRunner returns a DryRunner that prints to out, or to stderr if out is nil.
+func NewDryRunner(out io.Writer) *DryRunner {
+	if out == nil {
+		out = os.Stderr
+	}
+	return &DryRunner{out: out}
+}
+
+func (r *DryRunner) Run(ctx context.Context, c *Command) *Result {
+	argv := Quote(c.loggedArgv()...)
+	if c.dir == "" {
+		fmt.Fprintf(r.out, "Dry run: %v\n", argv)
+	} else {
+		fmt.Fprintf(r.out, "Dry run (cwd=%s) : %v\n", c.dir, argv)
+	}
+	return &Result{}
+}
+
+// Recording is a single recorded command invocation, as stored in a fixture file.
+type Recording struct {
+	Argv     []string `json:"argv"`
+	Dir      string   `json:"dir,omitempty"`
+	Stdout   string   `json:"stdout"`
+	Stderr   string   `json:"stderr"`
+	ExitCode int      `json:"exit_code"`
+	Signal   int      `json:"signal,omitempty"`
+	Err      string   `json:"error,omitempty"`
+}
+
+// Recorder runs commands through another runner and records every invocation and its output, so they can be saved
+// to a fixture file and replayed with a Replayer.
+type Recorder struct {
+	mu         sync.Mutex
+	path       string
+	inner      Runner
+	recordings []Recording
+}
+
+// NewRecorder returns a Recorder that runs commands with inner (RealRunner if nil) and saves them to path.
+func NewRecorder(path string, inner Runner) *Recorder {
+	if inner == nil {
+		inner = RealRunner{}
+	}
+	return &Recorder{path: path, inner: inner}
+}
+
+func (r *Recorder) Run(ctx context.Context, c *Command) *Result {
+	res := r.inner.Run(ctx, c)
+	recording := Recording{
+		Argv:     c.Argv(),
+		Dir:      c.dir,
+		Stdout:   res.Stdout.String(),
+		Stderr:   res.Stderr.String(),
+		ExitCode: res.ExitCode,
+	}
+	if res.Signaled {
+		recording.Signal = int(res.Signal)
+	}
+	if res.Err != nil {
+		recording.Err = res.Err.Error()
+	}
+	r.mu.Lock()
+	r.recordings = append(r.recordings, recording)
+	r.mu.Unlock()
+	return res
+}
+
+// Save writes all invocations recorded so far to the fixture file.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.recordings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode recordings: %w", err)
+	}
+	if err := os.WriteFile(r.path, data, 0644); err != nil {
+		return fmt.Errorf("could not write recordings: %w", err)
+	}
+	return nil
+}
+
+/*
+Replayer doesn't run commands, it answers each of them with the output of a matching invocation from a fixture file
+written by a Recorder. An invocation matches if it has the same argv and working directory; each recorded invocation
+is used once, in the order they were recorded.
+*/
+type Replayer struct {
+	mu         sync.Mutex
+	recordings []Recording
+	used       []bool
+}
+
+// NewReplayer loads the fixture file at path.
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read recordings: %w", err)
+	}
+	var recordings []Recording
+	if err := json.Unmarshal(data, &recordings); err != nil {
+		return nil, fmt.Errorf("could not decode recordings: %w", err)
+	}
+	return &Replayer{recordings: recordings, used: make([]bool, len(recordings))}, nil
+}
+
+func (r *Replayer) Run(ctx context.Context, c *Command) *Result {
+	argv := c.Argv()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, recording := range r.recordings {
+		if r.used[i] || recording.Dir != c.dir || !equalArgv(recording.Argv, argv) {
+			continue
+		}
+		r.used[i] = true
+		res := &Result{ExitCode: recording.ExitCode}
+		res.Stdout.WriteString(recording.Stdout)
+		res.Stderr.WriteString(recording.Stderr)
+		if recording.Signal != 0 {
+			res.Signaled = true
+			res.Signal = syscall.Signal(recording.Signal)
+		}
+		if recording.Err != "" {
+			res.Err = errors.New(recording.Err)
+		}
+		return res
+	}
+	return &Result{Err: fmt.Errorf("no recorded invocation left for %v", argv), ExitCode: -1}
+}
+
+func equalArgv(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
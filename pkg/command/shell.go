@@ -0,0 +1,152 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+Parse splits a shell command line into argv following POSIX shell quoting rules, without invoking a shell:
+words are separated by unquoted whitespace, single quotes preserve everything literally, double quotes preserve
+everything except backslash escapes of $ ` " \ and newline, and an unquoted backslash escapes the next character.
+An unquoted # at the start of a word begins a comment.
+
+Since nothing is expanded or interpreted, unquoted operators (| & ; < > ( )) and substitutions ($ and `) are rejected
+with an error rather than being passed on literally.
+*/
+func Parse(line string) ([]string, error) {
+	var argv []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				argv = append(argv, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '#' && !inWord:
+			// a comment runs to the end of the line
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '\\':
+			i++
+			if i == len(runes) {
+				return nil, fmt.Errorf("trailing backslash at position %d", i)
+			}
+			// backslash-newline is a line continuation
+			if runes[i] != '\n' {
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == '\'':
+			end := indexRune(runes, '\'', i+1)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote at position %d", i)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			inWord = true
+			i = end
+		case r == '"':
+			end, err := parseDoubleQuoted(runes, i, &word)
+			if err != nil {
+				return nil, err
+			}
+			inWord = true
+			i = end
+		case strings.ContainsRune("|&;<>()", r):
+			return nil, fmt.Errorf("unsupported shell operator %q at position %d", r, i)
+		case r == '$' || r == '`':
+			return nil, fmt.Errorf("unsupported shell substitution %q at position %d", r, i)
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		argv = append(argv, word.String())
+	}
+	return argv, nil
+}
+
+// parseDoubleQuoted writes the contents of the double-quoted string starting at runes[start] to word,
+// and returns the position of the closing quote.
+func parseDoubleQuoted(runes []rune, start int, word *strings.Builder) (int, error) {
+	for i := start + 1; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 < len(runes) && strings.ContainsRune("$`\"\\\n", runes[i+1]) {
+				i++
+				if runes[i] != '\n' {
+					word.WriteRune(runes[i])
+				}
+			} else {
+				word.WriteRune(r)
+			}
+		case '$', '`':
+			return 0, fmt.Errorf("unsupported shell substitution %q at position %d", r, i)
+		default:
+			word.WriteRune(r)
+		}
+	}
+	return 0, fmt.Errorf("unterminated double quote at position %d", start)
+}
+
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// NewFromLine returns a command for a shell command line, split into argv by Parse.
+func NewFromLine(line string) (*Command, error) {
+	argv, err := Parse(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("command line %q is empty", line)
+	}
+	return New(argv[0], argv[1:]...), nil
+}
+
+// Quote renders args as a shell command line that a POSIX shell would split back into exactly args.
+func Quote(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func quoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range arg {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_@%+=:,./-", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return arg
+	}
+	// inside single quotes nothing is special except the closing quote, which is written as '\''
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// String renders the command as a copy-pasteable shell command line.
+func (c *Command) String() string {
+	return Quote(c.Argv()...)
+}
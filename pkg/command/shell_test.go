@@ -0,0 +1,127 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"   \t\n", nil},
+		{"ls -la /tmp", []string{"ls", "-la", "/tmp"}},
+		{"  echo   a\tb\nc  ", []string{"echo", "a", "b", "c"}},
+		{`echo 'single $quoted "text"'`, []string{"echo", `single $quoted "text"`}},
+		{`echo "double 'quoted' text"`, []string{"echo", "double 'quoted' text"}},
+		{`echo "a \$ \` + "`" + ` \" \\ \n b"`, []string{"echo", "a $ ` \" \\ \\n b"}},
+		{`echo a\ b \'c\' \"d\"`, []string{"echo", "a b", "'c'", `"d"`}},
+		{"echo a\\\nb", []string{"echo", "ab"}},
+		{"echo \"a\\\nb\"", []string{"echo", "ab"}},
+		{`echo pre'mid'"post"`, []string{"echo", "premidpost"}},
+		{`echo '' ""`, []string{"echo", "", ""}},
+		{"echo a # a comment", []string{"echo", "a"}},
+		{"# only a comment", nil},
+		{"echo a#b", []string{"echo", "a#b"}},
+		{"echo a # comment\necho b", []string{"echo", "a", "echo", "b"}},
+		{`echo "#not a comment" '#' \#`, []string{"echo", "#not a comment", "#", "#"}},
+		{`echo '|' "&&" \; \>`, []string{"echo", "|", "&&", ";", ">"}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.line)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Parse(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		line string
+		err  string
+	}{
+		{"ls | wc -l", "unsupported shell operator '|'"},
+		{"a && b", "unsupported shell operator '&'"},
+		{"a; b", "unsupported shell operator ';'"},
+		{"cat < in", "unsupported shell operator '<'"},
+		{"echo > out", "unsupported shell operator '>'"},
+		{"(echo a)", "unsupported shell operator '('"},
+		{"echo $HOME", "unsupported shell substitution '$'"},
+		{"echo `date`", "unsupported shell substitution '`'"},
+		{`echo "$HOME"`, "unsupported shell substitution '$'"},
+		{`echo "a`, "unterminated double quote at position 5"},
+		{`echo 'a`, "unterminated single quote at position 5"},
+		{`echo a\`, "trailing backslash"},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.line)
+		if err == nil {
+			t.Errorf("Parse(%q) = %q, want an error", test.line, got)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Parse(%q) failed with %q, want %q", test.line, err, test.err)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"ls", "-la", "/tmp/a_b.c"}, "ls -la /tmp/a_b.c"},
+		{[]string{"echo", ""}, "echo ''"},
+		{[]string{"echo", "a b"}, "echo 'a b'"},
+		{[]string{"echo", "it's"}, `echo 'it'\''s'`},
+		{[]string{"echo", "$HOME", "*"}, "echo '$HOME' '*'"},
+	}
+	for _, test := range tests {
+		if got := Quote(test.args...); got != test.want {
+			t.Errorf("Quote(%q) = %s, want %s", test.args, got, test.want)
+		}
+	}
+}
+
+func TestQuoteParseRoundTrip(t *testing.T) {
+	args := [][]string{
+		{"echo"},
+		{"echo", ""},
+		{"printf", "%s\n", "a b", "  padded  "},
+		{"sh", "-c", `echo "$HOME" | tr a-z A-Z; exit 1`},
+		{"grep", "-e", "it's", "-e", `back\slash`, "-e", "`tick`"},
+		{"touch", "#hash", "a#b", "new\nline", "tab\there"},
+		{"echo", "ünïcødé", "日本語", "''", `""`},
+	}
+	for _, want := range args {
+		line := Quote(want...)
+		got, err := Parse(line)
+		if err != nil {
+			t.Errorf("Parse(Quote(%q)) = Parse(%s) failed: %v", want, line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(Quote(%q)) = Parse(%s) = %q", want, line, got)
+		}
+	}
+}
+
+func TestNewFromLine(t *testing.T) {
+	c, err := NewFromLine(`grep -r 'a b' .`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.executable != "grep" || !reflect.DeepEqual(c.args, []string{"-r", "a b", "."}) {
+		t.Errorf("NewFromLine parsed %q %q", c.executable, c.args)
+	}
+	if _, err := NewFromLine("  # nothing"); err == nil {
+		t.Error("NewFromLine accepted an empty command line")
+	}
+}
@@ -0,0 +1,24 @@
+package command
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// recordExitStatus fills in the structured exit status fields of the result from the finished process.
+func (r *Result) recordExitStatus(state *os.ProcessState, started time.Time) {
+	r.Duration = time.Since(started)
+	if state == nil {
+		r.ExitCode = -1
+		return
+	}
+	r.ExitCode = state.ExitCode()
+	r.UserTime = state.UserTime()
+	r.SystemTime = state.SystemTime()
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		r.Signaled = true
+		r.Signal = status.Signal()
+	}
+	r.MaxRSS = maxRSS(state)
+}
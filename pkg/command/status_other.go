@@ -0,0 +1,10 @@
+//go:build !unix
+
+package command
+
+import "os"
+
+// maxRSS returns 0, the maximum resident set size is only reported on Unix.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
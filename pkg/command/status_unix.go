@@ -0,0 +1,22 @@
+//go:build unix
+
+package command
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// maxRSS returns the maximum resident set size of the finished process in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// linux reports ru_maxrss in kilobytes, darwin in bytes
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss)
+	}
+	return int64(usage.Maxrss) * 1024
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// WithStdin feeds r to the command's stdin. It replaces any stdin set previously,
+// including the terminal's stdin when used with WithAttachToTerminal.
+func (c *Command) WithStdin(r io.Reader) *Command {
+	c.stdin = r
+	c.stdinString = nil
+	c.stdinFile = ""
+	return c
+}
+
+// WithStdinString feeds s to the command's stdin.
+func (c *Command) WithStdinString(s string) *Command {
+	c.stdin = nil
+	c.stdinString = &s
+	c.stdinFile = ""
+	return c
+}
+
+// WithStdinFile feeds the contents of the file at path to the command's stdin. The file is opened when the command runs.
+func (c *Command) WithStdinFile(path string) *Command {
+	c.stdin = nil
+	c.stdinString = nil
+	c.stdinFile = path
+	return c
+}
+
+// openStdin returns the reader to use as the command's stdin (nil if none was configured),
+// and a function to release it once the command has finished.
+func (c *Command) openStdin() (io.Reader, func(), error) {
+	if c.stdinString != nil {
+		// a fresh reader each time, so the command can be run more than once
+		return strings.NewReader(*c.stdinString), func() {}, nil
+	}
+	if c.stdinFile == "" {
+		return c.stdin, func() {}, nil
+	}
+	f, err := os.Open(c.stdinFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open stdin file: %w", err)
+	}
+	return f, func() { f.Close() }, nil
+}
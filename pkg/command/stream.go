@@ -0,0 +1,88 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// LineHandler is called with each line of output (without the trailing newline) as it is produced.
+type LineHandler func(line string)
+
+/*
+WithStdoutLineHandler calls handler with every line the command writes to stdout while it runs.
+Stdout is still captured in Result.Stdout. Handlers for stdout and stderr are called from
+different goroutines, so a handler registered for both must be safe for concurrent use.
+*/
+func (c *Command) WithStdoutLineHandler(handler LineHandler) *Command {
+	c.stdoutHandlers = append(c.stdoutHandlers, handler)
+	return c
+}
+
+// WithStderrLineHandler calls handler with every line the command writes to stderr while it runs.
+// Stderr is still captured in Result.Stderr.
+func (c *Command) WithStderrLineHandler(handler LineHandler) *Command {
+	c.stderrHandlers = append(c.stderrHandlers, handler)
+	return c
+}
+
+// WithStdoutWriter copies the command's stdout to w as it is produced, in addition to capturing it in Result.Stdout.
+func (c *Command) WithStdoutWriter(w io.Writer) *Command {
+	c.stdoutWriters = append(c.stdoutWriters, w)
+	return c
+}
+
+// WithStderrWriter copies the command's stderr to w as it is produced, in addition to capturing it in Result.Stderr.
+func (c *Command) WithStderrWriter(w io.Writer) *Command {
+	c.stderrWriters = append(c.stderrWriters, w)
+	return c
+}
+
+// outputWriter builds the writer a stream is copied to: the capture buffer, any tee writers, and any line handlers.
+// The returned lineWriters must be flushed once the process has exited.
+func outputWriter(buf io.Writer, writers []io.Writer, handlers []LineHandler) (io.Writer, []*lineWriter) {
+	if len(writers) == 0 && len(handlers) == 0 {
+		return buf, nil
+	}
+	all := []io.Writer{buf}
+	all = append(all, writers...)
+	var lineWriters []*lineWriter
+	for _, handler := range handlers {
+		lw := &lineWriter{handler: handler}
+		lineWriters = append(lineWriters, lw)
+		all = append(all, lw)
+	}
+	return io.MultiWriter(all...), lineWriters
+}
+
+// lineWriter splits whatever is written to it into lines and passes each complete line to handler.
+type lineWriter struct {
+	mu      sync.Mutex
+	handler LineHandler
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.handler(string(bytes.TrimSuffix(w.partial[:i], []byte("\r"))))
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush passes any trailing output that did not end with a newline to the handler.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		w.handler(string(bytes.TrimSuffix(w.partial, []byte("\r"))))
+		w.partial = nil
+	}
+}
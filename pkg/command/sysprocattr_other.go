@@ -0,0 +1,28 @@
+//go:build !unix
+
+package command
+
+import (
+	"errors"
+	"syscall"
+)
+
+// applySysProcAttr fails if the command asks for attributes that only exist on Unix.
+func (c *Command) applySysProcAttr() error {
+	switch {
+	case c.ownsGroup():
+		return errors.New("process groups, sessions and pseudo-terminals are only supported on unix")
+	case c.uid != nil || c.username != "":
+		return errors.New("running as another user is only supported on unix")
+	case c.chroot != "":
+		return errors.New("chroot is only supported on unix")
+	case c.cloneflags != 0:
+		return errors.New("namespaces are only supported on linux")
+	}
+	return nil
+}
+
+// signal sends sig to the process. Only SIGKILL is supported outside of Unix.
+func (c *Command) signal(sig syscall.Signal) error {
+	return c.process().Signal(sig)
+}
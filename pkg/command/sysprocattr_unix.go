@@ -0,0 +1,87 @@
+//go:build unix
+
+package command
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// applySysProcAttr sets the OS-specific attributes of the process: its process group, credentials, root and namespaces.
+func (c *Command) applySysProcAttr() error {
+	attr := &syscall.SysProcAttr{}
+	c.applyProcessGroup(attr)
+	credential, err := c.credential()
+	if err != nil {
+		return err
+	}
+	attr.Credential = credential
+	attr.Chroot = c.chroot
+	if err := c.applyNamespaces(attr); err != nil {
+		return err
+	}
+	c.cmd.SysProcAttr = attr
+	return nil
+}
+
+func (c *Command) applyProcessGroup(attr *syscall.SysProcAttr) {
+	if !c.ownsGroup() || c.pty {
+		// pty.Start sets up the session itself, and setpgid would fail for a session leader
+		return
+	}
+	if c.session {
+		attr.Setsid = true
+	} else {
+		attr.Setpgid = true
+	}
+}
+
+// signal sends sig to the process, or to its whole process group if it was started in one.
+func (c *Command) signal(sig syscall.Signal) error {
+	if c.ownsGroup() {
+		// the child is the group leader, so its pgid is its pid
+		return syscall.Kill(-c.Pid(), sig)
+	}
+	return c.process().Signal(sig)
+}
+
+// credential returns the credential to run the process with, or nil to run it as the current user.
+func (c *Command) credential() (*syscall.Credential, error) {
+	if c.username != "" {
+		return lookupCredential(c.username)
+	}
+	if c.uid == nil {
+		return nil, nil
+	}
+	return &syscall.Credential{Uid: *c.uid, Gid: c.gid, Groups: c.groups}, nil
+}
+
+func lookupCredential(name string) (*syscall.Credential, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not look up user: %w", err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected uid %q of user %s: %w", u.Uid, name, err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected gid %q of user %s: %w", u.Gid, name, err)
+	}
+	groupIds, err := u.GroupIds()
+	if err != nil {
+		return nil, fmt.Errorf("could not look up groups of user %s: %w", name, err)
+	}
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+	for _, groupId := range groupIds {
+		group, err := strconv.ParseUint(groupId, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected group id %q of user %s: %w", groupId, name, err)
+		}
+		credential.Groups = append(credential.Groups, uint32(group))
+	}
+	return credential, nil
+}